@@ -2,33 +2,181 @@ package mq
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
 	"github.com/hibiken/asynq"
 	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type AsynqClient struct {
-	log    *log.Helper   //日志
-	client *asynq.Client //客户端
+	log     *log.Helper   //日志
+	client  *asynq.Client //客户端
+	metrics *Metrics      //指标
+	tracing bool          //链路追踪
+
+	redisClientOpt asynq.RedisClientOpt  //redis 连接配置
+	redisOnce      sync.Once             //redis 客户端初始化
+	rdb            redis.UniversalClient //redis 客户端，用于顺序号与任务进度
+	sequenceOnce   sync.Once             //默认顺序号存储初始化
+	sequences      SequenceStore         //顺序号存储
+	inspectorOnce  sync.Once             //检查器初始化
+	insp           *asynq.Inspector      //检查器，用于等待响应
+}
+
+// AsynqClientOption 客户端选项
+type AsynqClientOption func(*AsynqClient)
+
+// WithClientMetrics 记录生产指标
+func WithClientMetrics(m *Metrics) AsynqClientOption {
+	return func(a *AsynqClient) {
+		a.metrics = m
+	}
+}
+
+// WithClientTracing 创建生产者 span，并通过消息头传递链路信息
+// 消费端需使用同版本的 AsynqServer 解析消息头
+func WithClientTracing() AsynqClientOption {
+	return func(a *AsynqClient) {
+		a.tracing = true
+	}
+}
+
+// WithClientSequenceStore 设置顺序号存储，默认使用同一 redis 的 RedisSequenceStore
+func WithClientSequenceStore(store SequenceStore) AsynqClientOption {
+	return func(a *AsynqClient) {
+		a.sequences = store
+	}
 }
 
 func NewAsynqClient(
 	logger log.Logger,
 	redisClientOpt asynq.RedisClientOpt,
+	opts ...AsynqClientOption,
 ) *AsynqClient {
 	a := &AsynqClient{
 		log:    log.NewHelper(log.With(logger, "module", "mq.asynq.client")),
 		client: asynq.NewClient(redisClientOpt),
+
+		redisClientOpt: redisClientOpt,
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
 	return a
 }
 
+var _ Producer = (*AsynqClient)(nil)
+
+// asynqOptions 将消息配置与生产选项转换为 asynq 参数
+func asynqOptions(b *MessageConfig, o *PublishOptions) []asynq.Option {
+	var opts []asynq.Option
+	switch {
+	case o.MaxRetry != nil:
+		opts = append(opts, asynq.MaxRetry(*o.MaxRetry))
+	case b.Retry != nil:
+		opts = append(opts, asynq.MaxRetry(b.Retry.MaxRetry))
+	}
+	switch {
+	case !o.ProcessAt.IsZero():
+		opts = append(opts, asynq.ProcessAt(o.ProcessAt))
+	case o.Delay > 0:
+		opts = append(opts, asynq.ProcessIn(o.Delay))
+	}
+	if o.UniqueKey != "" {
+		opts = append(opts, asynq.TaskID(o.UniqueKey))
+	}
+	if o.UniqueTTL > 0 {
+		opts = append(opts, asynq.Unique(o.UniqueTTL))
+	}
+	switch {
+	case o.Queue != "":
+		opts = append(opts, asynq.Queue(o.Queue))
+	case o.Priority != "":
+		opts = append(opts, asynq.Queue(string(o.Priority)))
+	}
+	if o.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(o.Timeout))
+	}
+	if o.Retention > 0 {
+		opts = append(opts, asynq.Retention(o.Retention))
+	}
+	if b.Batch != nil {
+		// 批量消费的消息投递到以 MessageConfig.Key 命名的任务分组，由服务端聚合
+		opts = append(opts, asynq.Group(b.Key))
+	}
+	return opts
+}
+
+// enqueue 投递任务
+func (a *AsynqClient) enqueue(ctx context.Context, b *MessageConfig, msg []byte, opts ...PublishOption) (info *asynq.TaskInfo, err error) {
+	o := NewPublishOptions(opts...)
+	if a.tracing {
+		var span trace.Span
+		ctx, span = startProducerSpan(ctx, "asynq", b, o)
+		defer func() {
+			if info != nil {
+				span.SetAttributes(attribute.String("messaging.message.id", info.ID))
+			}
+			endSpan(span, err)
+		}()
+	}
+	if a.metrics != nil {
+		defer func() {
+			a.metrics.recordPublish(ctx, b.Key, err)
+		}()
+	}
+	if o.OrderingKey != "" {
+		done, seqErr := assignSequence(ctx, a.sequenceStore(), b, o)
+		if seqErr != nil {
+			return nil, seqErr
+		}
+		defer func() {
+			done(err)
+		}()
+	}
+	payload, err := encodeEnvelope(o.Headers, msg)
+	if err != nil {
+		return nil, errors.Wrap(MessageEncodeFailed, err.Error())
+	}
+	info, err = a.client.EnqueueContext(ctx, asynq.NewTask(b.Metadata[MetaKeyAsynqQueue], payload), asynqOptions(b, o)...)
+	if err != nil {
+		if errors.Is(err, asynq.ErrDuplicateTask) || errors.Is(err, asynq.ErrTaskIDConflict) {
+			return nil, errors.Wrap(DuplicateMessage, err.Error())
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+// redis 返回基于客户端连接配置创建的 redis 客户端
+func (a *AsynqClient) redis() redis.UniversalClient {
+	a.redisOnce.Do(func() {
+		a.rdb = a.redisClientOpt.MakeRedisClient().(redis.UniversalClient)
+	})
+	return a.rdb
+}
+
+// sequenceStore 返回顺序号存储，未设置时基于客户端的 redis 连接配置创建
+func (a *AsynqClient) sequenceStore() SequenceStore {
+	a.sequenceOnce.Do(func() {
+		if a.sequences == nil {
+			a.sequences = NewRedisSequenceStore(a.redis(), "", 0)
+		}
+	})
+	return a.sequences
+}
+
 // ProducerNormalMessage 生产普通消息
 func (a *AsynqClient) ProducerNormalMessage(b *MessageConfig, msg []byte) error {
-	_, err := a.client.Enqueue(asynq.NewTask(b.Metadata[MetaKeyAsynqQueue], msg))
+	_, err := a.enqueue(context.Background(), b, msg)
 	if err != nil {
 		a.log.Error("Asynq 普通消息推送失败,err:", err)
 		return errors.Wrap(GeneralMessageDeliveryFailed, err.Error())
@@ -38,7 +186,7 @@ func (a *AsynqClient) ProducerNormalMessage(b *MessageConfig, msg []byte) error
 
 // ProducerDelayMessage 生产延时消息
 func (a *AsynqClient) ProducerDelayMessage(b *MessageConfig, msg []byte, t time.Duration) error {
-	_, err := a.client.Enqueue(asynq.NewTask(b.Metadata[MetaKeyAsynqQueue], msg), asynq.ProcessIn(t))
+	_, err := a.enqueue(context.Background(), b, msg, WithDelay(t))
 	if err != nil {
 		a.log.Error("Asynq 延迟消息推送失败,err:", err)
 		return errors.Wrap(DelayedMessageDeliveryFailed, err.Error())
@@ -46,15 +194,68 @@ func (a *AsynqClient) ProducerDelayMessage(b *MessageConfig, msg []byte, t time.
 	return nil
 }
 
+// Publish 生产消息，返回消息 ID
+func (a *AsynqClient) Publish(ctx context.Context, b *MessageConfig, msg []byte, opts ...PublishOption) (string, error) {
+	info, err := a.enqueue(ctx, b, msg, opts...)
+	if err != nil {
+		if errors.Is(err, DuplicateMessage) {
+			return "", err
+		}
+		a.log.Error("Asynq 消息推送失败,err:", err)
+		return "", errors.Wrap(GeneralMessageDeliveryFailed, err.Error())
+	}
+	return info.ID, nil
+}
+
 type AsynqServer struct {
 	log             *log.Helper               //日志
 	lock            sync.Mutex                //锁
-	server          *asynq.Server             //服务端
-	scheduler       *asynq.Scheduler          //调度器
+	server          *asynq.Server             //服务端，启动时创建
+	config          asynq.Config              //服务端配置
+	batches         map[string]BatchOptions   //批量消费者，键为任务分组名称
+	cron            *asynqCronScheduler       //定时任务调度器
 	normalConsumers map[*MessageConfig]Handle //普通消费者
-	cronConsumers   map[*MessageConfig]string //定时消费者
+	retryDelayFunc  asynq.RetryDelayFunc      //默认重试间隔
+	started         bool                      //是否已启动
+	middlewares     []Middleware              //消费者中间件
+	redisClientOpt  asynq.RedisClientOpt      //redis 连接配置
+	redisOnce       sync.Once                 //redis 客户端初始化
+	rdb             redis.UniversalClient     //redis 客户端，用于任务进度与重新投递
+	requeueOnce     sync.Once                 //重新投递客户端初始化
+	requeueClient   *asynq.Client             //重新投递客户端
+	insp            *asynq.Inspector          //检查器，用于读取任务参数
 }
 
+// AsynqServerOption 服务端选项
+type AsynqServerOption func(*AsynqServer)
+
+// WithServerMetrics 记录消费指标
+func WithServerMetrics(m *Metrics) AsynqServerOption {
+	return func(a *AsynqServer) {
+		a.middlewares = append(a.middlewares, m.Observe())
+	}
+}
+
+// WithServerTracing 创建消费者 span，并从消息头恢复生产者的链路
+func WithServerTracing() AsynqServerOption {
+	return func(a *AsynqServer) {
+		a.middlewares = append([]Middleware{Tracing()}, a.middlewares...)
+	}
+}
+
+// WithServerMiddleware 为所有消费者添加中间件
+func WithServerMiddleware(m ...Middleware) AsynqServerOption {
+	return func(a *AsynqServer) {
+		a.middlewares = append(a.middlewares, m...)
+	}
+}
+
+var (
+	_ transport.Server = (*AsynqServer)(nil)
+	_ CronManager      = (*AsynqServer)(nil)
+	_ BatchServer      = (*AsynqServer)(nil)
+)
+
 func NwDefaultAsynqConfig() asynq.Config {
 	return asynq.Config{
 		// 指定要使用多少并发工作人员
@@ -68,6 +269,7 @@ func NwDefaultAsynqConfig() asynq.Config {
 	}
 }
 
+// NewDefaultSchedulerOpts 默认调度参数，Location 作为定时任务的默认时区
 func NewDefaultSchedulerOpts(logger log.Logger) *asynq.SchedulerOpts {
 	return &asynq.SchedulerOpts{
 		Logger:   log.NewHelper(log.With(logger, "module", "mq.asynq")),
@@ -81,78 +283,250 @@ func NewAsynqServer(
 	redisClientOpt asynq.RedisClientOpt,
 	asynqConfig asynq.Config,
 	schedulerOpts *asynq.SchedulerOpts,
+	opts ...AsynqServerOption,
 ) *AsynqServer {
 	a := &AsynqServer{
 		log:             log.NewHelper(log.With(logger, "module", "mq.asynq.server")),
 		lock:            sync.Mutex{},
 		normalConsumers: make(map[*MessageConfig]Handle),
-		cronConsumers:   make(map[*MessageConfig]string),
+		batches:         make(map[string]BatchOptions),
+		redisClientOpt:  redisClientOpt,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.retryDelayFunc = asynqConfig.RetryDelayFunc
+	if a.retryDelayFunc == nil {
+		a.retryDelayFunc = asynq.DefaultRetryDelayFunc
+	}
+	asynqConfig.RetryDelayFunc = a.retryDelay
+	isFailure := asynqConfig.IsFailure
+	asynqConfig.IsFailure = func(err error) bool {
+		// 重新投递的消息不计入失败次数
+		if _, ok := RequeueDelay(err); ok {
+			return false
+		}
+		if isFailure != nil {
+			return isFailure(err)
+		}
+		return true
 	}
-	a.server = asynq.NewServer(
-		redisClientOpt,
-		asynqConfig,
-	)
-	a.scheduler = asynq.NewScheduler(redisClientOpt, schedulerOpts)
+	a.config = asynqConfig
+	var location *time.Location
+	if schedulerOpts != nil {
+		location = schedulerOpts.Location
+	}
+	a.cron = newAsynqCronScheduler(a.log, asynq.NewClient(redisClientOpt), a.redis, location)
 	return a
 }
 
+// retryDelay 按消息配置的重试策略计算重试间隔，未配置时使用默认策略
+func (a *AsynqServer) retryDelay(n int, e error, t *asynq.Task) time.Duration {
+	if d, ok := RequeueDelay(e); ok {
+		return d
+	}
+	a.lock.Lock()
+	var policy *RetryPolicy
+	for b := range a.normalConsumers {
+		if b.Metadata[MetaKeyAsynqQueue] == t.Type() {
+			policy = b.Retry
+			break
+		}
+	}
+	a.lock.Unlock()
+	if policy == nil {
+		return a.retryDelayFunc(n, e, t)
+	}
+	return policy.NextDelay(n)
+}
+
+// register 注册消费者，仅允许在启动前调用
+func (a *AsynqServer) register(b *MessageConfig, handle Handle) error {
+	if a.started {
+		return errors.Wrap(ServerAlreadyStarted, b.Key)
+	}
+	typename := b.Metadata[MetaKeyAsynqQueue]
+	for exist := range a.normalConsumers {
+		if exist.Metadata[MetaKeyAsynqQueue] == typename {
+			return errors.Wrap(ConsumerAlreadyRegistered, b.Key)
+		}
+	}
+	a.normalConsumers[b] = handle
+	return nil
+}
+
 // ConsumerNormalRegister 注册一个普通消费者
-func (a *AsynqServer) ConsumerNormalRegister(b *MessageConfig, handle Handle) {
+func (a *AsynqServer) ConsumerNormalRegister(b *MessageConfig, handle Handle) error {
 	a.lock.Lock()
 	defer a.lock.Unlock()
-	a.normalConsumers[b] = handle
+	return a.register(b, handle)
 }
 
-// ConsumerCronRegister 注册一个定时任务
-func (a *AsynqServer) ConsumerCronRegister(b *MessageConfig, handle Handle, cron string) {
+// ConsumerCronRegister 注册一个定时任务，任务名称为 MessageConfig.Key，消息体为空
+func (a *AsynqServer) ConsumerCronRegister(b *MessageConfig, handle Handle, cron string) error {
 	a.lock.Lock()
 	defer a.lock.Unlock()
-	a.normalConsumers[b] = handle
-	a.cronConsumers[b] = cron
+	if _, err := parseCronSpec(cron, nil); err != nil {
+		return errors.Wrap(CronSpecInvalid, err.Error())
+	}
+	if err := a.register(b, handle); err != nil {
+		return err
+	}
+	if err := a.cron.AddCron(b, &CronJob{Name: b.Key, Spec: cron}); err != nil {
+		delete(a.normalConsumers, b)
+		return err
+	}
+	return nil
 }
 
-// Start 启动
-func (a *AsynqServer) Start(ctx context.Context) error {
-	a.log.Info("Asynq server start")
-	if len(a.cronConsumers) > 0 {
-		for business, cron := range a.cronConsumers {
-			b := *business
-			_, err := a.scheduler.Register(cron, asynq.NewTask(b.Metadata[MetaKeyAsynqQueue], []byte{}))
-			if err != nil {
-				a.log.Error("Asynq 定时消息注册失败,err:", err)
-				return errors.Wrap(CronMessageDeliveryFailed, err.Error())
-			}
+// AddCron 添加定时任务，运行时可调用；消费者需在启动前通过 ConsumerNormalRegister 注册
+func (a *AsynqServer) AddCron(b *MessageConfig, job *CronJob) error {
+	return a.cron.AddCron(b, job)
+}
+
+// PauseCron 暂停定时任务
+func (a *AsynqServer) PauseCron(name string) error {
+	return a.cron.PauseCron(name)
+}
+
+// ResumeCron 恢复定时任务
+func (a *AsynqServer) ResumeCron(name string) error {
+	return a.cron.ResumeCron(name)
+}
+
+// RemoveCron 删除定时任务
+func (a *AsynqServer) RemoveCron(name string) error {
+	return a.cron.RemoveCron(name)
+}
+
+// ListCrons 列出所有定时任务
+func (a *AsynqServer) ListCrons() []*CronEntry {
+	return a.cron.ListCrons()
+}
+
+// redis 返回基于连接配置创建的 redis 客户端
+func (a *AsynqServer) redis() redis.UniversalClient {
+	a.redisOnce.Do(func() {
+		a.rdb = a.redisClientOpt.MakeRedisClient().(redis.UniversalClient)
+	})
+	return a.rdb
+}
+
+// taskStore 返回任务进度与取消标记存储
+func (a *AsynqServer) taskStore() *asynqTaskStore {
+	return &asynqTaskStore{rdb: a.redis()}
+}
+
+// handler 将 Handle 包装为 asynq 处理函数
+func (a *AsynqServer) handler(b *MessageConfig, h Handle) asynq.HandlerFunc {
+	h = Chain(a.middlewares...)(h)
+	if b.Limit != nil {
+		h = Limit(*b.Limit)(h)
+	}
+	return func(ctx context.Context, task *asynq.Task) error {
+		headers, body := decodeEnvelope(task.Payload())
+		ctx = newAsynqMessageContext(ctx, b, headers)
+		if w := task.ResultWriter(); w != nil {
+			ctx = NewReplyContext(ctx, w)
+		}
+		info, _ := MessageFromContext(ctx)
+		id := info.ID
+		ctx = NewProgressContext(ctx, &asynqProgressReporter{store: a.taskStore, id: id})
+		err := h(ctx, body)
+		if err != nil && ctx.Err() != nil && a.taskStore().canceled(context.WithoutCancel(ctx), id) {
+			// 用户取消的任务不再重试
+			a.log.Info("Asynq 任务已取消,key:", b.Metadata[MetaKeyAsynqQueue], "id:", id)
+			return fmt.Errorf("%w: %w", TaskCanceled, asynq.SkipRetry)
+		}
+		if delay, ok := RequeueDelay(err); ok {
+			a.log.Debug("Asynq 消息稍后重新投递,key:", b.Metadata[MetaKeyAsynqQueue], "err:", err)
+			return a.requeue(context.WithoutCancel(ctx), task, info, delay, err)
 		}
-		if err := a.scheduler.Start(); err != nil {
-			a.log.Error("Asynq 调度器启动失败,err:", err)
+		if err != nil {
+			a.log.Error("Asynq 消息业务处理失败,key:", b.Metadata[MetaKeyAsynqQueue], "metadata:", b.Metadata, "headers:", headers, "body:", logBody(body), "err:", err)
+			retried, _ := asynq.GetRetryCount(ctx)
+			maxRetry, _ := asynq.GetMaxRetry(ctx)
+			if IsNonRetryable(err) || retried >= maxRetry {
+				// 不可重试或重试次数用尽，进入死信
+				return a.deadLetter(context.WithoutCancel(ctx), b, task, info, err)
+			}
 			return err
 		}
+		return nil
+	}
+}
+
+// Start 启动，非阻塞，信号处理交由 kratos.App
+func (a *AsynqServer) Start(ctx context.Context) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.started {
+		return ServerAlreadyStarted
 	}
+	a.log.Info("Asynq server start")
 	if len(a.normalConsumers) > 0 {
 		mux := asynq.NewServeMux()
 		for business, handle := range a.normalConsumers {
-			b := *business
-			h := handle
-			mux.HandleFunc(b.Metadata[MetaKeyAsynqQueue], func(ctx context.Context, task *asynq.Task) error {
-				err := h(ctx, task.Payload())
-				if err != nil {
-					a.log.Error("Asynq 消息业务处理失败,key:", b.Metadata[MetaKeyAsynqQueue], "metadata:", b.Metadata, "body:", string(task.Payload()), "err:", err)
-					return err
-				}
-				return nil
-			})
+			mux.Handle(business.Metadata[MetaKeyAsynqQueue], a.handler(business, handle))
 		}
-		if err := a.server.Run(mux); err != nil {
+		a.server = asynq.NewServer(a.redisClientOpt, a.serverConfig())
+		if err := a.server.Start(mux); err != nil {
 			a.log.Error("Asynq服务启动失败,err:", err)
 			return err
 		}
 	}
+	a.cron.Start()
+	a.started = true
 	return nil
 }
 
+// newAsynqMessageContext 将 asynq 任务信息放入 context
+// 重新投递的副本通过消息头保留原消息 ID 与已重试次数
+func newAsynqMessageContext(ctx context.Context, b *MessageConfig, headers map[string]string) context.Context {
+	info := &MessageInfo{Key: b.Key, Headers: headers}
+	info.ID, _ = asynq.GetTaskID(ctx)
+	info.Queue, _ = asynq.GetQueueName(ctx)
+	info.Retried, _ = asynq.GetRetryCount(ctx)
+	info.MaxRetry, _ = asynq.GetMaxRetry(ctx)
+	if id := headers[headerRequeueID]; id != "" {
+		info.ID = id
+		retried, _ := strconv.Atoi(headers[headerRequeueRetried])
+		info.Retried += retried
+		info.MaxRetry += retried
+	}
+	return NewMessageContext(ctx, info)
+}
+
+// Stop 停止拉取新消息并等待处理中的消息完成，超过 ctx 截止时间后返回
+// 未在截止时间内完成的消息由 asynq 在 ShutdownTimeout 后退回队列
 func (a *AsynqServer) Stop(ctx context.Context) error {
-	a.server.Shutdown()
-	a.scheduler.Shutdown()
-	a.log.Info("Asynq server stop")
-	return nil
+	a.lock.Lock()
+	if !a.started {
+		a.lock.Unlock()
+		return nil
+	}
+	a.started = false
+	server := a.server
+	a.lock.Unlock()
+
+	cronCtx := a.cron.Stop()
+	if server != nil {
+		server.Stop()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-cronCtx.Done()
+		if server != nil {
+			server.Shutdown()
+		}
+	}()
+	select {
+	case <-done:
+		a.log.Info("Asynq server stop")
+		return nil
+	case <-ctx.Done():
+		a.log.Warn("Asynq server stop timeout,err:", ctx.Err())
+		return ctx.Err()
+	}
 }
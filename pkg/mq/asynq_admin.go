@@ -0,0 +1,325 @@
+package mq
+
+import (
+	"encoding/json"
+	stdhttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+)
+
+// AdminAuthFunc 管理接口鉴权，返回错误时拒绝请求（401）
+type AdminAuthFunc func(r *stdhttp.Request) error
+
+// AdminOption 管理接口选项
+type AdminOption func(*AsynqAdmin)
+
+// WithAdminAuth 设置管理接口鉴权，未设置时拒绝所有请求
+func WithAdminAuth(f AdminAuthFunc) AdminOption {
+	return func(a *AsynqAdmin) {
+		a.auth = f
+	}
+}
+
+// WithAdminCron 启用定时任务管理接口
+func WithAdminCron(m CronManager) AdminOption {
+	return func(a *AsynqAdmin) {
+		a.cron = m
+	}
+}
+
+// AsynqAdmin asynq 队列管理 HTTP 接口
+//
+//	GET    /queues                              队列列表（大小、延迟等）
+//	GET    /queues/{queue}/tasks?state=&page=&size=  任务列表，state: pending/active/scheduled/retry/archived/completed
+//	GET    /queues/{queue}/tasks/{id}           任务详情
+//	POST   /queues/{queue}/tasks/{id}/run       立即执行
+//	POST   /queues/{queue}/tasks/{id}/archive   归档
+//	DELETE /queues/{queue}/tasks/{id}           删除
+//	POST   /queues/{queue}/pause                暂停队列
+//	POST   /queues/{queue}/unpause              恢复队列
+//	GET    /crons                               定时任务列表（需 WithAdminCron）
+//	POST   /crons/{name}/pause                  暂停定时任务（需 WithAdminCron）
+//	POST   /crons/{name}/resume                 恢复定时任务（需 WithAdminCron）
+type AsynqAdmin struct {
+	log       *log.Helper      //日志
+	inspector *asynq.Inspector //检查器
+	auth      AdminAuthFunc    //鉴权
+	cron      CronManager      //定时任务管理
+	mux       *stdhttp.ServeMux
+}
+
+var _ stdhttp.Handler = (*AsynqAdmin)(nil)
+
+func NewAsynqAdmin(
+	logger log.Logger,
+	redisClientOpt asynq.RedisClientOpt,
+	opts ...AdminOption,
+) *AsynqAdmin {
+	a := &AsynqAdmin{
+		log:       log.NewHelper(log.With(logger, "module", "mq.asynq.admin")),
+		inspector: asynq.NewInspector(redisClientOpt),
+		mux:       stdhttp.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.auth == nil {
+		a.log.Warn("Asynq 管理接口未设置鉴权，所有请求将被拒绝")
+	}
+	a.mux.HandleFunc("GET /queues", a.listQueues)
+	a.mux.HandleFunc("GET /queues/{queue}/tasks", a.listTasks)
+	a.mux.HandleFunc("GET /queues/{queue}/tasks/{id}", a.getTask)
+	a.mux.HandleFunc("POST /queues/{queue}/tasks/{id}/run", a.runTask)
+	a.mux.HandleFunc("POST /queues/{queue}/tasks/{id}/archive", a.archiveTask)
+	a.mux.HandleFunc("DELETE /queues/{queue}/tasks/{id}", a.deleteTask)
+	a.mux.HandleFunc("POST /queues/{queue}/pause", a.pauseQueue)
+	a.mux.HandleFunc("POST /queues/{queue}/unpause", a.unpauseQueue)
+	if a.cron != nil {
+		a.mux.HandleFunc("GET /crons", a.listCrons)
+		a.mux.HandleFunc("POST /crons/{name}/pause", a.pauseCron)
+		a.mux.HandleFunc("POST /crons/{name}/resume", a.resumeCron)
+	}
+	return a
+}
+
+// Register 挂载到 kratos http.Server，例如 admin.Register(srv, "/admin/mq")
+func (a *AsynqAdmin) Register(srv *http.Server, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	srv.HandlePrefix(prefix+"/", stdhttp.StripPrefix(prefix, a))
+}
+
+// Close 关闭检查器
+func (a *AsynqAdmin) Close() error {
+	return a.inspector.Close()
+}
+
+// ServeHTTP 实现 http.Handler，未设置鉴权时返回 401
+func (a *AsynqAdmin) ServeHTTP(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	if a.auth == nil {
+		a.writeError(w, stdhttp.StatusUnauthorized, AdminAuthRequired)
+		return
+	}
+	if err := a.auth(r); err != nil {
+		a.writeError(w, stdhttp.StatusUnauthorized, err)
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// adminQueue 队列信息
+type adminQueue struct {
+	Queue     string `json:"queue"`
+	Size      int    `json:"size"`
+	Pending   int    `json:"pending"`
+	Active    int    `json:"active"`
+	Scheduled int    `json:"scheduled"`
+	Retry     int    `json:"retry"`
+	Archived  int    `json:"archived"`
+	Completed int    `json:"completed"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+	LatencyMs int64  `json:"latency_ms"`
+	Paused    bool   `json:"paused"`
+}
+
+// adminTask 任务信息
+type adminTask struct {
+	ID            string            `json:"id"`
+	Queue         string            `json:"queue"`
+	Type          string            `json:"type"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Payload       string            `json:"payload"`
+	State         string            `json:"state"`
+	MaxRetry      int               `json:"max_retry"`
+	Retried       int               `json:"retried"`
+	LastErr       string            `json:"last_err,omitempty"`
+	LastFailedAt  time.Time         `json:"last_failed_at"`
+	NextProcessAt time.Time         `json:"next_process_at"`
+	CompletedAt   time.Time         `json:"completed_at"`
+	Result        string            `json:"result,omitempty"`
+}
+
+func newAdminTask(t *asynq.TaskInfo) *adminTask {
+	headers, payload := decodeEnvelope(t.Payload)
+	return &adminTask{
+		ID:            t.ID,
+		Queue:         t.Queue,
+		Type:          t.Type,
+		Headers:       headers,
+		Payload:       string(payload),
+		State:         t.State.String(),
+		MaxRetry:      t.MaxRetry,
+		Retried:       t.Retried,
+		LastErr:       t.LastErr,
+		LastFailedAt:  t.LastFailedAt,
+		NextProcessAt: t.NextProcessAt,
+		CompletedAt:   t.CompletedAt,
+		Result:        string(t.Result),
+	}
+}
+
+func (a *AsynqAdmin) listQueues(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	queues, err := a.inspector.Queues()
+	if err != nil {
+		a.writeInspectorError(w, err)
+		return
+	}
+	res := make([]*adminQueue, 0, len(queues))
+	for _, queue := range queues {
+		info, err := a.inspector.GetQueueInfo(queue)
+		if err != nil {
+			a.writeInspectorError(w, err)
+			return
+		}
+		res = append(res, &adminQueue{
+			Queue:     info.Queue,
+			Size:      info.Size,
+			Pending:   info.Pending,
+			Active:    info.Active,
+			Scheduled: info.Scheduled,
+			Retry:     info.Retry,
+			Archived:  info.Archived,
+			Completed: info.Completed,
+			Processed: info.Processed,
+			Failed:    info.Failed,
+			LatencyMs: info.Latency.Milliseconds(),
+			Paused:    info.Paused,
+		})
+	}
+	a.writeJSON(w, res)
+}
+
+func (a *AsynqAdmin) listTasks(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	queue := r.PathValue("queue")
+	page, err := queryInt(r, "page", 1)
+	if err != nil {
+		a.writeError(w, stdhttp.StatusBadRequest, err)
+		return
+	}
+	size, err := queryInt(r, "size", 20)
+	if err != nil {
+		a.writeError(w, stdhttp.StatusBadRequest, err)
+		return
+	}
+	var list func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+	switch state := r.URL.Query().Get("state"); state {
+	case "", "pending":
+		list = a.inspector.ListPendingTasks
+	case "active":
+		list = a.inspector.ListActiveTasks
+	case "scheduled":
+		list = a.inspector.ListScheduledTasks
+	case "retry":
+		list = a.inspector.ListRetryTasks
+	case "archived":
+		list = a.inspector.ListArchivedTasks
+	case "completed":
+		list = a.inspector.ListCompletedTasks
+	default:
+		a.writeError(w, stdhttp.StatusBadRequest, errors.Errorf("unknown task state %q", state))
+		return
+	}
+	tasks, err := list(queue, asynq.Page(page), asynq.PageSize(size))
+	if err != nil {
+		a.writeInspectorError(w, err)
+		return
+	}
+	res := make([]*adminTask, 0, len(tasks))
+	for _, t := range tasks {
+		res = append(res, newAdminTask(t))
+	}
+	a.writeJSON(w, res)
+}
+
+func (a *AsynqAdmin) getTask(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	info, err := a.inspector.GetTaskInfo(r.PathValue("queue"), r.PathValue("id"))
+	if err != nil {
+		a.writeInspectorError(w, err)
+		return
+	}
+	a.writeJSON(w, newAdminTask(info))
+}
+
+func (a *AsynqAdmin) runTask(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	a.writeResult(w, a.inspector.RunTask(r.PathValue("queue"), r.PathValue("id")))
+}
+
+func (a *AsynqAdmin) archiveTask(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	a.writeResult(w, a.inspector.ArchiveTask(r.PathValue("queue"), r.PathValue("id")))
+}
+
+func (a *AsynqAdmin) deleteTask(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	a.writeResult(w, a.inspector.DeleteTask(r.PathValue("queue"), r.PathValue("id")))
+}
+
+func (a *AsynqAdmin) pauseQueue(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	a.writeResult(w, a.inspector.PauseQueue(r.PathValue("queue")))
+}
+
+func (a *AsynqAdmin) unpauseQueue(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	a.writeResult(w, a.inspector.UnpauseQueue(r.PathValue("queue")))
+}
+
+func (a *AsynqAdmin) listCrons(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	a.writeJSON(w, a.cron.ListCrons())
+}
+
+func (a *AsynqAdmin) pauseCron(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	a.writeResult(w, a.cron.PauseCron(r.PathValue("name")))
+}
+
+func (a *AsynqAdmin) resumeCron(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	a.writeResult(w, a.cron.ResumeCron(r.PathValue("name")))
+}
+
+// queryInt 读取正整数查询参数
+func queryInt(r *stdhttp.Request, key string, def int) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, errors.Errorf("invalid %s %q", key, v)
+	}
+	return n, nil
+}
+
+func (a *AsynqAdmin) writeJSON(w stdhttp.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"data": data}); err != nil {
+		a.log.Error("Asynq 管理接口响应写入失败,err:", err)
+	}
+}
+
+func (a *AsynqAdmin) writeError(w stdhttp.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// writeInspectorError 按错误类型返回状态码
+func (a *AsynqAdmin) writeInspectorError(w stdhttp.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound), errors.Is(err, CronNotFound):
+		a.writeError(w, stdhttp.StatusNotFound, err)
+	default:
+		a.log.Error("Asynq 管理接口操作失败,err:", err)
+		a.writeError(w, stdhttp.StatusInternalServerError, err)
+	}
+}
+
+// writeResult 写入无数据的操作结果
+func (a *AsynqAdmin) writeResult(w stdhttp.ResponseWriter, err error) {
+	if err != nil {
+		a.writeInspectorError(w, err)
+		return
+	}
+	a.writeJSON(w, nil)
+}
@@ -0,0 +1,100 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/hibiken/asynq"
+)
+
+// allowAll 测试用鉴权，放行所有请求
+func allowAll(*http.Request) error { return nil }
+
+func TestAsynqAdminAuth(t *testing.T) {
+	admin := NewAsynqAdmin(log.DefaultLogger, asynq.RedisClientOpt{Addr: "127.0.0.1:0"},
+		WithAdminAuth(func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				return errors.New("unauthorized")
+			}
+			return nil
+		}),
+	)
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/queues", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/queues/default/tasks?state=unknown", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	admin.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("authorized status = %d, want 400", rec.Code)
+	}
+}
+
+func TestAsynqAdminAuthRequired(t *testing.T) {
+	admin := NewAsynqAdmin(log.DefaultLogger, asynq.RedisClientOpt{Addr: "127.0.0.1:0"})
+	for _, target := range []string{"/queues", "/queues/default/tasks?state=unknown"} {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", target, rec.Code)
+		}
+	}
+}
+
+func TestAsynqAdminBadRequest(t *testing.T) {
+	admin := NewAsynqAdmin(log.DefaultLogger, asynq.RedisClientOpt{Addr: "127.0.0.1:0"}, WithAdminAuth(allowAll))
+	for _, target := range []string{
+		"/queues/default/tasks?state=unknown",
+		"/queues/default/tasks?page=0",
+		"/queues/default/tasks?size=abc",
+	} {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestAsynqAdminCron(t *testing.T) {
+	srv := newTestAsynqServer()
+	b := &MessageConfig{Key: "report", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:report"}}
+	if err := srv.AddCron(b, &CronJob{Name: "daily", Spec: "@daily"}); err != nil {
+		t.Fatal(err)
+	}
+	admin := NewAsynqAdmin(log.DefaultLogger, asynq.RedisClientOpt{Addr: "127.0.0.1:0"}, WithAdminAuth(allowAll), WithAdminCron(srv))
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/crons/daily/pause", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("pause status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/crons", nil))
+	var resp struct {
+		Data []*CronEntry `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 1 || !resp.Data[0].Paused {
+		t.Errorf("crons = %+v", resp.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/crons/missing/resume", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	_ = srv.Stop(context.Background())
+}
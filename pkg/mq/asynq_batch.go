@@ -0,0 +1,226 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+)
+
+// batchMagic 聚合批次前缀，与消息信封前缀区分
+var batchMagic = []byte("\x00mq\x02")
+
+// encodeBatch 编码聚合批次，每条消息保留原始负载（含消息信封）
+func encodeBatch(payloads [][]byte) []byte {
+	size := len(batchMagic)
+	for _, p := range payloads {
+		size += binary.MaxVarintLen64 + len(p)
+	}
+	buf := make([]byte, 0, size)
+	buf = append(buf, batchMagic...)
+	for _, p := range payloads {
+		buf = binary.AppendUvarint(buf, uint64(len(p)))
+		buf = append(buf, p...)
+	}
+	return buf
+}
+
+// decodeBatch 解码聚合批次，不是聚合批次时返回 false
+func decodeBatch(payload []byte) ([][]byte, bool) {
+	if !bytes.HasPrefix(payload, batchMagic) {
+		return nil, false
+	}
+	var payloads [][]byte
+	rest := payload[len(batchMagic):]
+	for len(rest) > 0 {
+		n, size := binary.Uvarint(rest)
+		if size <= 0 || uint64(len(rest)-size) < n {
+			return nil, false
+		}
+		payloads = append(payloads, rest[size:size+int(n)])
+		rest = rest[size+int(n):]
+	}
+	return payloads, true
+}
+
+// logBody 返回用于日志的消息体，聚合批次只记录消息数量，避免输出二进制内容
+func logBody(body []byte) string {
+	if payloads, ok := decodeBatch(body); ok {
+		return "batch of " + strconv.Itoa(len(payloads)) + " messages"
+	}
+	return string(body)
+}
+
+// aggregateBatch 将分组中的任务聚合为一个任务
+func aggregateBatch(tasks []*asynq.Task) *asynq.Task {
+	payloads := make([][]byte, len(tasks))
+	for i, t := range tasks {
+		payloads[i] = t.Payload()
+	}
+	return asynq.NewTask(tasks[0].Type(), encodeBatch(payloads))
+}
+
+// ConsumerBatchRegister 注册一个批量消费者，仅允许在启动前调用
+// 批次由 asynq 的任务分组聚合，生产端需为 MessageConfig 设置 Batch 才会将消息投递到分组，否则消息逐条处理；
+// 分组参数作用于整个服务端，asynq.Config 未设置时 GroupMaxSize 取所有批量消费者 Size 的最大值、
+// GroupMaxDelay 取 Wait 的最小值、GroupGracePeriod 取 max(Wait, 1s)，超过消费者 Size 的批次拆分后处理；
+// 部分失败时仅失败的消息作为新批次重试，重试次数用尽后进入死信
+func (a *AsynqServer) ConsumerBatchRegister(b *MessageConfig, h BatchHandle, opts ...BatchOption) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	o := newBatchOptions(b, opts...)
+	if err := a.register(b, a.batchHandle(b, h, o)); err != nil {
+		return err
+	}
+	if b.Batch == nil {
+		a.log.Warn("Asynq 批量消费者未设置 MessageConfig.Batch，消息将逐条处理,key:", b.Key)
+	}
+	a.batches[b.Key] = o
+	return nil
+}
+
+// serverConfig 返回 asynq 服务端配置，存在批量消费者时设置任务分组的聚合参数
+func (a *AsynqServer) serverConfig() asynq.Config {
+	cfg := a.config
+	if len(a.batches) == 0 {
+		return cfg
+	}
+	var (
+		size int
+		wait time.Duration
+	)
+	for _, o := range a.batches {
+		size = max(size, o.Size)
+		if wait == 0 || o.Wait < wait {
+			wait = o.Wait
+		}
+	}
+	if cfg.GroupMaxSize == 0 {
+		cfg.GroupMaxSize = size
+	}
+	if cfg.GroupMaxDelay == 0 {
+		cfg.GroupMaxDelay = wait
+	}
+	if cfg.GroupGracePeriod == 0 {
+		cfg.GroupGracePeriod = max(wait, time.Second)
+	}
+	aggregator := cfg.GroupAggregator
+	cfg.GroupAggregator = asynq.GroupAggregatorFunc(func(group string, tasks []*asynq.Task) *asynq.Task {
+		if _, ok := a.batches[group]; !ok && aggregator != nil {
+			return aggregator.Aggregate(group, tasks)
+		}
+		return aggregateBatch(tasks)
+	})
+	return cfg
+}
+
+// batchHandle 将批量消费方法包装为处理聚合任务的 Handle
+func (a *AsynqServer) batchHandle(b *MessageConfig, h BatchHandle, o BatchOptions) Handle {
+	return func(ctx context.Context, msg []byte) error {
+		payloads, ok := decodeBatch(msg)
+		if !ok {
+			// 未投递到分组的消息逐条处理
+			payloads = [][]byte{msg}
+		}
+		failed, err := handleBatch(ctx, h, payloads, o.Size)
+		if len(failed) == 0 {
+			return nil
+		}
+		info, _ := MessageFromContext(ctx)
+		maxRetry := info.MaxRetry
+		if b.Retry != nil {
+			maxRetry = min(maxRetry, b.Retry.MaxRetry)
+		}
+		exhausted := info.Retried >= maxRetry || allNonRetryable(failed)
+		if len(failed) == len(payloads) {
+			// 整批失败，由 asynq 重试当前任务
+			if exhausted {
+				return NonRetryable(err)
+			}
+			return err
+		}
+		rest := make([][]byte, 0, len(failed))
+		for i, p := range payloads {
+			if failed[i] != nil {
+				rest = append(rest, p)
+			}
+		}
+		a.log.Warn("Asynq 批量消费部分失败,key:", b.Key, "failed:", len(rest), "total:", len(payloads), "err:", err)
+		return a.retryBatch(ctx, b, info, rest, err, maxRetry, exhausted)
+	}
+}
+
+// handleBatch 按 size 拆分批次并调用批量消费方法，返回失败消息的下标与错误
+func handleBatch(ctx context.Context, h BatchHandle, payloads [][]byte, size int) (map[int]error, error) {
+	failed := make(map[int]error)
+	var last error
+	for start := 0; start < len(payloads); start += size {
+		chunk := payloads[start:min(start+size, len(payloads))]
+		msgs := make([][]byte, len(chunk))
+		for i, p := range chunk {
+			_, msgs[i] = decodeEnvelope(p)
+		}
+		err := callBatch(ctx, h, msgs)
+		if err == nil {
+			continue
+		}
+		last = err
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) {
+			for i, e := range batchErr.Failed {
+				if e != nil && i >= 0 && i < len(chunk) {
+					failed[start+i] = e
+				}
+			}
+			continue
+		}
+		for i := range chunk {
+			failed[start+i] = err
+		}
+	}
+	return failed, last
+}
+
+// allNonRetryable 判断失败的消息是否都不可重试
+func allNonRetryable(failed map[int]error) bool {
+	for _, err := range failed {
+		if !IsNonRetryable(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// retryBatch 将部分失败的消息作为新批次投递，当前任务视为完成
+// 重试次数已用尽时新批次直接归档到死信队列（未配置时为原队列），死信中只包含失败的消息
+func (a *AsynqServer) retryBatch(ctx context.Context, b *MessageConfig, info *MessageInfo, payloads [][]byte, cause error, maxRetry int, exhausted bool) error {
+	ctx = context.WithoutCancel(ctx)
+	if exhausted {
+		queue := deadLetterQueue(b)
+		if queue == "" {
+			queue = info.Queue
+		}
+		_, err := a.archiveCopy(ctx, queue, b.Metadata[MetaKeyAsynqQueue], nil, encodeBatch(payloads), info, cause)
+		return err
+	}
+	retried := info.Retried + 1
+	payload, err := encodeEnvelope(map[string]string{
+		headerRequeueID:      info.ID,
+		headerRequeueRetried: strconv.Itoa(retried),
+	}, encodeBatch(payloads))
+	if err != nil {
+		return errors.Wrap(MessageEncodeFailed, err.Error())
+	}
+	task := asynq.NewTask(b.Metadata[MetaKeyAsynqQueue], payload)
+	client, _ := a.requeuer()
+	_, err = client.EnqueueContext(ctx, task,
+		asynq.Queue(info.Queue),
+		asynq.MaxRetry(maxRetry-retried),
+		asynq.ProcessIn(a.retryDelay(info.Retried, cause, task)),
+	)
+	return err
+}
@@ -0,0 +1,232 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// asynqCronJob 已注册的定时任务
+type asynqCronJob struct {
+	scheduler *asynqCronScheduler
+	config    *MessageConfig
+	job       *CronJob
+	schedule  cron.Schedule
+	entryID   cron.EntryID // 暂停时为 0
+}
+
+// asynqCronScheduler 基于 cron 的 asynq 定时任务调度器
+// 与 asynq.Scheduler 相比支持动态消息体、单任务时区、随机延迟以及运行时管理
+type asynqCronScheduler struct {
+	log    *log.Helper
+	client *asynq.Client
+	redis  func() redis.UniversalClient // 多实例去重锁
+	cron   *cron.Cron
+	mu     sync.Mutex
+	jobs   map[string]*asynqCronJob
+}
+
+// asynqCronLockPrefix 定时任务触发锁前缀
+const asynqCronLockPrefix = "mq:cron:"
+
+var _ CronManager = (*asynqCronScheduler)(nil)
+
+func newAsynqCronScheduler(logger *log.Helper, client *asynq.Client, rdb func() redis.UniversalClient, location *time.Location) *asynqCronScheduler {
+	if location == nil {
+		location = time.Local
+	}
+	return &asynqCronScheduler{
+		log:    logger,
+		client: client,
+		redis:  rdb,
+		cron:   cron.New(cron.WithLocation(location)),
+		jobs:   make(map[string]*asynqCronJob),
+	}
+}
+
+// parseCronSpec 解析 cron 表达式，指定时区时添加 CRON_TZ 前缀
+func parseCronSpec(spec string, location *time.Location) (cron.Schedule, error) {
+	if location != nil && !strings.HasPrefix(spec, "TZ=") && !strings.HasPrefix(spec, "CRON_TZ=") {
+		spec = "CRON_TZ=" + location.String() + " " + spec
+	}
+	return cron.ParseStandard(spec)
+}
+
+// AddCron 添加定时任务
+func (s *asynqCronScheduler) AddCron(b *MessageConfig, job *CronJob) error {
+	if job.Name == "" {
+		return errors.Wrap(CronSpecInvalid, "cron job name is required")
+	}
+	schedule, err := parseCronSpec(job.Spec, job.Location)
+	if err != nil {
+		return errors.Wrap(CronSpecInvalid, err.Error())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return errors.Wrap(CronAlreadyExists, job.Name)
+	}
+	j := &asynqCronJob{
+		scheduler: s,
+		config:    b,
+		job:       job,
+		schedule:  schedule,
+	}
+	j.entryID = s.cron.Schedule(schedule, j)
+	s.jobs[job.Name] = j
+	return nil
+}
+
+// PauseCron 暂停定时任务
+func (s *asynqCronScheduler) PauseCron(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return errors.Wrap(CronNotFound, name)
+	}
+	if j.entryID != 0 {
+		s.cron.Remove(j.entryID)
+		j.entryID = 0
+	}
+	return nil
+}
+
+// ResumeCron 恢复定时任务
+func (s *asynqCronScheduler) ResumeCron(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return errors.Wrap(CronNotFound, name)
+	}
+	if j.entryID == 0 {
+		j.entryID = s.cron.Schedule(j.schedule, j)
+	}
+	return nil
+}
+
+// RemoveCron 删除定时任务
+func (s *asynqCronScheduler) RemoveCron(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return errors.Wrap(CronNotFound, name)
+	}
+	if j.entryID != 0 {
+		s.cron.Remove(j.entryID)
+	}
+	delete(s.jobs, name)
+	return nil
+}
+
+// ListCrons 列出所有定时任务，按名称排序
+func (s *asynqCronScheduler) ListCrons() []*CronEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*CronEntry, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		location := s.cron.Location()
+		if j.job.Location != nil {
+			location = j.job.Location
+		}
+		e := &CronEntry{
+			Name:     j.job.Name,
+			Key:      j.config.Key,
+			Spec:     j.job.Spec,
+			Location: location.String(),
+			Jitter:   j.job.Jitter.String(),
+			Paused:   j.entryID == 0,
+		}
+		if j.entryID != 0 {
+			entry := s.cron.Entry(j.entryID)
+			e.Next, e.Prev = entry.Next, entry.Prev
+			if e.Next.IsZero() {
+				// 调度器未启动时按当前时间推算
+				e.Next = j.schedule.Next(time.Now().In(s.cron.Location()))
+			}
+		}
+		res = append(res, e)
+	}
+	slices.SortFunc(res, func(a, b *CronEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
+
+// Start 启动调度
+func (s *asynqCronScheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop 停止调度，返回的 context 在正在执行的投递完成后结束
+func (s *asynqCronScheduler) Stop() context.Context {
+	return s.cron.Stop()
+}
+
+// Run 定时触发，生成消息体并投递
+func (j *asynqCronJob) Run() {
+	s := j.scheduler
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Error("Asynq 定时任务 panic,name:", j.job.Name, "err:", r)
+		}
+	}()
+	s.mu.Lock()
+	entryID := j.entryID
+	s.mu.Unlock()
+	j.publish(context.Background(), s.cron.Entry(entryID).Prev)
+}
+
+// publish 投递 prev 时刻触发的消息
+// 多实例同时调度时以任务名称与触发时刻在 redis 中加锁，同一时刻只有一个实例投递
+func (j *asynqCronJob) publish(ctx context.Context, prev time.Time) {
+	s := j.scheduler
+	if !prev.IsZero() {
+		ok, err := j.lock(ctx, prev)
+		if err != nil {
+			s.log.Error("Asynq 定时任务加锁失败,name:", j.job.Name, "err:", err)
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+	payload := j.job.Payload
+	if j.job.PayloadFunc != nil {
+		var err error
+		if payload, err = j.job.PayloadFunc(ctx); err != nil {
+			s.log.Error("Asynq 定时任务消息体生成失败,name:", j.job.Name, "err:", err)
+			return
+		}
+	}
+	if payload == nil {
+		payload = []byte{}
+	}
+	o := &PublishOptions{}
+	if j.job.Jitter > 0 {
+		o.Delay = rand.N(j.job.Jitter)
+	}
+	_, err := s.client.EnqueueContext(ctx, asynq.NewTask(j.config.Metadata[MetaKeyAsynqQueue], payload), asynqOptions(j.config, o)...)
+	if err != nil {
+		s.log.Error("Asynq 定时消息投递失败,name:", j.job.Name, "err:", errors.Wrap(CronMessageDeliveryFailed, err.Error()))
+	}
+}
+
+// lock 获取 prev 时刻的触发锁，保留到下一次触发之后（至少 1 分钟），覆盖各实例间的时钟误差
+func (j *asynqCronJob) lock(ctx context.Context, prev time.Time) (bool, error) {
+	ttl := max(j.schedule.Next(prev).Sub(prev), time.Minute)
+	key := fmt.Sprintf("%s%s:%d", asynqCronLockPrefix, j.job.Name, prev.Unix())
+	return j.scheduler.redis().SetNX(ctx, key, 1, ttl).Result()
+}
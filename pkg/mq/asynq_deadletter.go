@@ -0,0 +1,318 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// 进入死信队列的副本的消息头
+const (
+	headerDeadLetterQueue = "mq-dead-letter-queue" // 进入死信前所在的队列
+	headerDeadLetterErr   = "mq-dead-letter-err"   // 最后一次失败原因
+	headerDeadLetterAt    = "mq-dead-letter-at"    // 进入死信的时间
+)
+
+// deadLetter 消息进入死信
+// 未配置死信队列时由 asynq 在原队列归档；配置后将副本投递到死信队列并归档，撤销当前任务
+func (a *AsynqServer) deadLetter(ctx context.Context, b *MessageConfig, task *asynq.Task, info *MessageInfo, cause error) error {
+	queue := deadLetterQueue(b)
+	if queue == "" || queue == info.Queue {
+		return fmt.Errorf("%w: %w", cause, asynq.SkipRetry)
+	}
+	var opts []asynq.Option
+	_, insp := a.requeuer()
+	taskID, _ := asynq.GetTaskID(ctx)
+	if origin, err := insp.GetTaskInfo(info.Queue, taskID); err == nil {
+		opts = copyOptions(origin)
+	}
+	headers, body := decodeEnvelope(task.Payload())
+	next, err := a.archiveCopy(ctx, queue, task.Type(), headers, body, info, cause, opts...)
+	if err != nil {
+		a.log.Error("Asynq 消息转入死信队列失败，在原队列归档,key:", task.Type(), "id:", info.ID, "err:", err)
+		return fmt.Errorf("%w: %w", cause, asynq.SkipRetry)
+	}
+	if fwdErr := a.taskStore().setForward(ctx, info.ID, next); fwdErr != nil {
+		a.log.Warn("Asynq 任务跳转记录失败,id:", info.ID, "err:", fwdErr)
+	}
+	return fmt.Errorf("%w: %w", cause, asynq.RevokeTask)
+}
+
+// archiveCopy 将消息副本归档到 queue，返回副本的任务 ID
+// 副本通过消息头保留消息 ID、已重试次数、原队列与失败原因，剩余重试次数在重新投递后生效
+func (a *AsynqServer) archiveCopy(ctx context.Context, queue, typename string, headers map[string]string, body []byte, info *MessageInfo, cause error, opts ...asynq.Option) (string, error) {
+	headers = maps.Clone(headers)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers[headerRequeueID] = info.ID
+	headers[headerRequeueRetried] = strconv.Itoa(info.Retried)
+	headers[headerDeadLetterQueue] = info.Queue
+	headers[headerDeadLetterErr] = cause.Error()
+	headers[headerDeadLetterAt] = time.Now().Format(time.RFC3339Nano)
+	payload, err := encodeEnvelope(headers, body)
+	if err != nil {
+		return "", errors.Wrap(MessageEncodeFailed, err.Error())
+	}
+	client, insp := a.requeuer()
+	next := uuid.NewString()
+	// 先以延迟任务投递再归档，归档后可通过死信队列重新投递
+	opts = append(opts,
+		asynq.TaskID(next),
+		asynq.Queue(queue),
+		asynq.MaxRetry(max(info.MaxRetry-info.Retried, 0)),
+		asynq.ProcessIn(asynqTaskTTL),
+	)
+	if _, err := client.EnqueueContext(ctx, asynq.NewTask(typename, payload), opts...); err != nil {
+		return "", err
+	}
+	if err := insp.ArchiveTask(queue, next); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// AsynqDeadLetterQueue asynq 死信队列
+// asynq 将重试耗尽或不可重试的任务归档（archived），归档集合即为死信；
+// MessageConfig 配置了 RetryPolicy.DeadLetterQueue 时只查找该队列，否则查找所有队列；用户取消的任务不属于死信
+type AsynqDeadLetterQueue struct {
+	log       *log.Helper           //日志
+	client    *asynq.Client         //客户端，用于将死信队列中的消息投递回原队列
+	inspector *asynq.Inspector      //检查器
+	rdb       redis.UniversalClient //redis 客户端，用于读取取消标记
+}
+
+var _ DeadLetterQueue = (*AsynqDeadLetterQueue)(nil)
+
+func NewAsynqDeadLetterQueue(
+	logger log.Logger,
+	redisClientOpt asynq.RedisClientOpt,
+) *AsynqDeadLetterQueue {
+	return &AsynqDeadLetterQueue{
+		log:       log.NewHelper(log.With(logger, "module", "mq.asynq.deadletter")),
+		client:    asynq.NewClient(redisClientOpt),
+		inspector: asynq.NewInspector(redisClientOpt),
+		rdb:       redisClientOpt.MakeRedisClient().(redis.UniversalClient),
+	}
+}
+
+// queues 返回消息配置的死信所在的队列
+func (a *AsynqDeadLetterQueue) queues(b *MessageConfig) ([]string, error) {
+	if queue := deadLetterQueue(b); queue != "" {
+		return []string{queue}, nil
+	}
+	return a.inspector.Queues()
+}
+
+// isDeadLetter 判断归档任务是否为该消息配置的死信
+// 任务被取消时 asynq 可能以 context 错误归档，因此同时检查取消标记
+func (a *AsynqDeadLetterQueue) isDeadLetter(ctx context.Context, b *MessageConfig, t *asynq.TaskInfo) bool {
+	if t.Type != b.Metadata[MetaKeyAsynqQueue] || strings.HasPrefix(t.LastErr, TaskCanceled.Error()) {
+		return false
+	}
+	id := t.ID
+	if headers, _ := decodeEnvelope(t.Payload); headers[headerRequeueID] != "" {
+		id = headers[headerRequeueID]
+	}
+	store := &asynqTaskStore{rdb: a.rdb}
+	return !store.canceled(ctx, id)
+}
+
+// archivedTasks 按顺序遍历属于该消息配置的归档任务，fn 返回 false 时停止
+func (a *AsynqDeadLetterQueue) archivedTasks(ctx context.Context, b *MessageConfig, fn func(t *asynq.TaskInfo) bool) error {
+	queues, err := a.queues(b)
+	if err != nil {
+		return err
+	}
+	const pageSize = 100
+	for _, queue := range queues {
+		for page := 1; ; page++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			list, err := a.inspector.ListArchivedTasks(queue, asynq.Page(page), asynq.PageSize(pageSize))
+			if err != nil {
+				if errors.Is(err, asynq.ErrQueueNotFound) {
+					break
+				}
+				return err
+			}
+			for _, t := range list {
+				if a.isDeadLetter(ctx, b, t) && !fn(t) {
+					return nil
+				}
+			}
+			if len(list) < pageSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// findTask 根据 ID 查找属于该消息配置的归档任务
+func (a *AsynqDeadLetterQueue) findTask(ctx context.Context, b *MessageConfig, id string) (*asynq.TaskInfo, error) {
+	queues, err := a.queues(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, queue := range queues {
+		info, err := a.inspector.GetTaskInfo(queue, id)
+		if err != nil {
+			if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if info.State == asynq.TaskStateArchived && a.isDeadLetter(ctx, b, info) {
+			return info, nil
+		}
+	}
+	return nil, DeadLetterNotFound
+}
+
+// ListDeadLetters 分页列出死信消息，page 从 1 开始，size 默认 20
+// 遍历到所需的一页后即停止，不会读取全部归档任务
+func (a *AsynqDeadLetterQueue) ListDeadLetters(ctx context.Context, b *MessageConfig, page, size int) ([]*DeadLetter, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 20
+	}
+	skip := (page - 1) * size
+	res := make([]*DeadLetter, 0, size)
+	err := a.archivedTasks(ctx, b, func(t *asynq.TaskInfo) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		res = append(res, newAsynqDeadLetter(b, t))
+		return len(res) < size
+	})
+	if err != nil {
+		a.log.Error("Asynq 死信列表查询失败,err:", err)
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetDeadLetter 查看一条死信消息
+func (a *AsynqDeadLetterQueue) GetDeadLetter(ctx context.Context, b *MessageConfig, id string) (*DeadLetter, error) {
+	info, err := a.findTask(ctx, b, id)
+	if err != nil {
+		return nil, err
+	}
+	return newAsynqDeadLetter(b, info), nil
+}
+
+// ReplayDeadLetter 重新投递一条死信消息
+// 死信队列中的消息投递回原队列，原队列中的消息直接重新执行
+func (a *AsynqDeadLetterQueue) ReplayDeadLetter(ctx context.Context, b *MessageConfig, id string) error {
+	info, err := a.findTask(ctx, b, id)
+	if err != nil {
+		return err
+	}
+	headers, body := decodeEnvelope(info.Payload)
+	origin := headers[headerDeadLetterQueue]
+	if origin == "" || origin == info.Queue {
+		err = a.inspector.RunTask(info.Queue, info.ID)
+	} else {
+		err = a.moveBack(ctx, info, origin, headers, body)
+	}
+	if err != nil {
+		a.log.Error("Asynq 死信重新投递失败,err:", err)
+		return err
+	}
+	return nil
+}
+
+// moveBack 将死信队列中的消息投递回原队列，任务 ID 不变
+func (a *AsynqDeadLetterQueue) moveBack(ctx context.Context, info *asynq.TaskInfo, origin string, headers map[string]string, body []byte) error {
+	headers = maps.Clone(headers)
+	delete(headers, headerDeadLetterQueue)
+	delete(headers, headerDeadLetterErr)
+	delete(headers, headerDeadLetterAt)
+	payload, err := encodeEnvelope(headers, body)
+	if err != nil {
+		return errors.Wrap(MessageEncodeFailed, err.Error())
+	}
+	opts := append(copyOptions(info), asynq.TaskID(info.ID), asynq.Queue(origin), asynq.MaxRetry(info.MaxRetry))
+	if _, err := a.client.EnqueueContext(ctx, asynq.NewTask(info.Type, payload), opts...); err != nil {
+		return err
+	}
+	if err := a.inspector.DeleteTask(info.Queue, info.ID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+		return err
+	}
+	return nil
+}
+
+// PurgeDeadLetters 清空死信消息，返回删除数量
+func (a *AsynqDeadLetterQueue) PurgeDeadLetters(ctx context.Context, b *MessageConfig) (int, error) {
+	var tasks []*asynq.TaskInfo
+	err := a.archivedTasks(ctx, b, func(t *asynq.TaskInfo) bool {
+		tasks = append(tasks, t)
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, t := range tasks {
+		if err := a.inspector.DeleteTask(t.Queue, t.ID); err != nil {
+			if errors.Is(err, asynq.ErrTaskNotFound) {
+				continue
+			}
+			a.log.Error("Asynq 死信删除失败,err:", err)
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Close 关闭客户端与检查器
+func (a *AsynqDeadLetterQueue) Close() error {
+	if err := a.client.Close(); err != nil {
+		return err
+	}
+	if err := a.rdb.Close(); err != nil {
+		return err
+	}
+	return a.inspector.Close()
+}
+
+// newAsynqDeadLetter 转换归档任务，副本的重试次数与失败原因从消息头恢复
+func newAsynqDeadLetter(b *MessageConfig, t *asynq.TaskInfo) *DeadLetter {
+	headers, payload := decodeEnvelope(t.Payload)
+	d := &DeadLetter{
+		ID:       t.ID,
+		Key:      b.Key,
+		Queue:    t.Queue,
+		Payload:  payload,
+		Retried:  t.Retried,
+		MaxRetry: t.MaxRetry,
+		LastErr:  t.LastErr,
+		FailedAt: t.LastFailedAt,
+	}
+	if retried, err := strconv.Atoi(headers[headerRequeueRetried]); err == nil {
+		d.Retried += retried
+		d.MaxRetry += retried
+	}
+	if e := headers[headerDeadLetterErr]; e != "" && d.LastErr == "" {
+		d.LastErr = e
+	}
+	if at, err := time.Parse(time.RFC3339Nano, headers[headerDeadLetterAt]); err == nil && d.FailedAt.IsZero() {
+		d.FailedAt = at
+	}
+	return d
+}
@@ -0,0 +1,147 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/hibiken/asynq"
+)
+
+// waitDeadLetters 等待死信数量达到 n
+func waitDeadLetters(t *testing.T, dlq *AsynqDeadLetterQueue, b *MessageConfig, n int) []*DeadLetter {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		list, err := dlq.ListDeadLetters(context.Background(), b, 1, 100)
+		if err == nil && len(list) == n {
+			return list
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dead letters = %d, err = %v, want %d", len(list), err, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 配置死信队列时消息转入该队列，重新投递后回到原队列且消息 ID 不变
+func TestAsynqDeadLetterQueueDestination(t *testing.T) {
+	b := &MessageConfig{
+		Key:      "dead",
+		Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:dead"},
+		Retry:    &RetryPolicy{MaxRetry: 3, DeadLetterQueue: "dead"},
+	}
+	seen := make(chan *MessageInfo, 2)
+	var calls atomic.Int32
+	opt := startTestAsynq(t, func(srv *AsynqServer) {
+		_ = srv.ConsumerNormalRegister(b, func(ctx context.Context, msg []byte) error {
+			info, _ := MessageFromContext(ctx)
+			seen <- info
+			if calls.Add(1) == 1 {
+				return NonRetryable(errors.New("bad message"))
+			}
+			return nil
+		})
+	})
+	client := NewAsynqClient(log.DefaultLogger, opt)
+	dlq := NewAsynqDeadLetterQueue(log.DefaultLogger, opt)
+	defer dlq.Close()
+
+	id, err := client.Publish(context.Background(), b, []byte("hi"), WithRetention(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-seen
+	list := waitDeadLetters(t, dlq, b, 1)
+	d := list[0]
+	if d.Queue != "dead" || string(d.Payload) != "hi" || d.LastErr != "bad message" || d.MaxRetry != 3 || d.FailedAt.IsZero() {
+		t.Fatalf("unexpected dead letter %+v", d)
+	}
+	if status, err := client.TaskStatus(context.Background(), b, id); err != nil || status.State != TaskStateFailed {
+		t.Fatalf("task status = %+v, err = %v", status, err)
+	}
+	insp := asynq.NewInspector(opt)
+	defer insp.Close()
+	if q, err := insp.GetQueueInfo("default"); err != nil || q.Archived != 0 {
+		t.Fatalf("message should not be archived in the origin queue, queue = %+v err = %v", q, err)
+	}
+
+	if err := dlq.ReplayDeadLetter(context.Background(), b, d.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case info := <-seen:
+		if info.ID != id || info.Queue != "default" || info.Headers[headerDeadLetterErr] != "" {
+			t.Fatalf("unexpected replayed message %+v", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead letter was not replayed")
+	}
+	waitDeadLetters(t, dlq, b, 0)
+}
+
+// 死信分页列出，用户取消的任务不属于死信
+func TestAsynqDeadLetterQueueList(t *testing.T) {
+	b := &MessageConfig{Key: "list", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:list"}}
+	started := make(chan struct{}, 1)
+	opt := startTestAsynq(t, func(srv *AsynqServer) {
+		_ = srv.ConsumerNormalRegister(b, func(ctx context.Context, msg []byte) error {
+			if string(msg) == "cancel" {
+				started <- struct{}{}
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return errors.New("failed")
+		})
+	})
+	client := NewAsynqClient(log.DefaultLogger, opt)
+	dlq := NewAsynqDeadLetterQueue(log.DefaultLogger, opt)
+	defer dlq.Close()
+
+	id, err := client.Publish(context.Background(), b, []byte("cancel"), WithMaxRetry(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := client.CancelTask(context.Background(), b, id); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.Publish(context.Background(), b, []byte("fail"), WithMaxRetry(0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitDeadLetters(t, dlq, b, 3)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := client.TaskStatus(context.Background(), b, id)
+		if err == nil && status.State == TaskStateCanceled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task status = %+v, err = %v", status, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for page, want := range []int{2, 1, 0} {
+		list, err := dlq.ListDeadLetters(context.Background(), b, page+1, 2)
+		if err != nil || len(list) != want {
+			t.Fatalf("page %d: dead letters = %d, err = %v, want %d", page+1, len(list), err, want)
+		}
+		for _, d := range list {
+			if d.ID == id {
+				t.Fatalf("canceled task listed as dead letter %+v", d)
+			}
+		}
+	}
+	if _, err := dlq.GetDeadLetter(context.Background(), b, id); !errors.Is(err, DeadLetterNotFound) {
+		t.Fatalf("expected DeadLetterNotFound for canceled task, got %v", err)
+	}
+	if n, err := dlq.PurgeDeadLetters(context.Background(), b); err != nil || n != 3 {
+		t.Fatalf("purged %d, err = %v", n, err)
+	}
+}
@@ -0,0 +1,75 @@
+package mq
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// AsynqQueueMetrics asynq 队列积压指标，采集时通过 Inspector 查询
+type AsynqQueueMetrics struct {
+	inspector    *asynq.Inspector    //检查器
+	registration metric.Registration //回调注册
+}
+
+// NewAsynqQueueMetrics 注册 mq_queue_depth{queue,state} 与 mq_queue_latency_seconds{queue}
+func NewAsynqQueueMetrics(meter metric.Meter, redisClientOpt asynq.RedisClientOpt) (*AsynqQueueMetrics, error) {
+	depth, err := meter.Int64ObservableGauge(
+		"mq_queue_depth",
+		metric.WithDescription("The number of tasks in the queue by state"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	latency, err := meter.Float64ObservableGauge(
+		"mq_queue_latency_seconds",
+		metric.WithDescription("The time elapsed since the oldest pending task was enqueued"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	a := &AsynqQueueMetrics{inspector: asynq.NewInspector(redisClientOpt)}
+	a.registration, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		queues, err := a.inspector.Queues()
+		if err != nil {
+			return err
+		}
+		for _, queue := range queues {
+			info, err := a.inspector.GetQueueInfo(queue)
+			if err != nil {
+				continue
+			}
+			states := map[string]int{
+				"pending":   info.Pending,
+				"active":    info.Active,
+				"scheduled": info.Scheduled,
+				"retry":     info.Retry,
+				"archived":  info.Archived,
+			}
+			for state, n := range states {
+				o.ObserveInt64(depth, int64(n), metric.WithAttributes(
+					attribute.String("queue", queue),
+					attribute.String("state", state),
+				))
+			}
+			o.ObserveFloat64(latency, info.Latency.Seconds(), metric.WithAttributes(attribute.String("queue", queue)))
+		}
+		return nil
+	}, depth, latency)
+	if err != nil {
+		_ = a.inspector.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// Close 注销回调并关闭检查器
+func (a *AsynqQueueMetrics) Close() error {
+	if err := a.registration.Unregister(); err != nil {
+		return err
+	}
+	return a.inspector.Close()
+}
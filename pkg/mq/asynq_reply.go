@@ -0,0 +1,71 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+)
+
+var _ Requester = (*AsynqClient)(nil)
+
+// Request 生产消息并等待响应，响应通过 asynq 的任务结果存储返回
+// 任务处理完成后结果至少保留 timeout（不少于 1 分钟）
+func (a *AsynqClient) Request(ctx context.Context, b *MessageConfig, msg []byte, timeout time.Duration, opts ...PublishOption) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	info, err := a.enqueue(ctx, b, msg, append(opts, WithRetention(max(timeout, time.Minute)))...)
+	if err != nil {
+		a.log.Error("Asynq 请求消息推送失败,err:", err)
+		return nil, errors.Wrap(GeneralMessageDeliveryFailed, err.Error())
+	}
+	return a.AwaitReply(ctx, info.Queue, info.ID)
+}
+
+// AwaitReply 等待任务完成并返回响应，适用于先 Publish（需设置 WithRetention）后等待的场景
+func (a *AsynqClient) AwaitReply(ctx context.Context, queue, id string) ([]byte, error) {
+	interval := 20 * time.Millisecond
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		info, err := a.inspector().GetTaskInfo(queue, id)
+		switch {
+		case err == nil && info.State == asynq.TaskStateCompleted:
+			return info.Result, nil
+		case err == nil && info.State == asynq.TaskStateArchived:
+			return nil, errors.Wrap(RequestFailed, info.LastErr)
+		case errors.Is(err, asynq.ErrTaskNotFound):
+			// 消息被重新投递，改为等待副本
+			if next := a.taskStore().forward(ctx, id); next != "" && next != id {
+				id = next
+				continue
+			}
+			// 任务完成后未保留结果，或结果已过期
+			return nil, errors.Wrapf(ReplyNotFound, "task %s", id)
+		case err != nil:
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.Wrapf(ReplyTimeout, "task %s", id)
+			}
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		interval = min(interval*2, 500*time.Millisecond)
+		timer.Reset(interval)
+	}
+}
+
+// inspector 返回用于查询任务结果的检查器
+func (a *AsynqClient) inspector() *asynq.Inspector {
+	a.inspectorOnce.Do(func() {
+		a.insp = asynq.NewInspector(a.redisClientOpt)
+	})
+	return a.insp
+}
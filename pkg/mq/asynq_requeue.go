@@ -0,0 +1,94 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+)
+
+// 重新投递副本的消息头
+const (
+	headerRequeueID      = "mq-requeue-id"      // 原消息 ID
+	headerRequeueRetried = "mq-requeue-retried" // 原消息已重试次数
+)
+
+// requeue 稍后重新投递消息，不计入重试次数
+// 仍有重试次数时交由 asynq 放入重试队列，任务 ID 不变；
+// 重试次数已用尽（含 MaxRetry 为 0）时 asynq 会直接归档，因此投递一份延迟执行的副本并撤销当前任务，
+// 副本通过消息头保留消息 ID 与已重试次数，任务状态、取消与等待响应通过原消息 ID 跳转到副本
+func (a *AsynqServer) requeue(ctx context.Context, task *asynq.Task, info *MessageInfo, delay time.Duration, err error) error {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if retried < maxRetry {
+		return err
+	}
+	next, copyErr := a.enqueueCopy(ctx, task, info, delay)
+	if copyErr != nil {
+		a.log.Error("Asynq 消息重新投递失败,key:", task.Type(), "id:", info.ID, "err:", copyErr)
+		return err
+	}
+	if fwdErr := a.taskStore().setForward(ctx, info.ID, next); fwdErr != nil {
+		a.log.Warn("Asynq 任务跳转记录失败,id:", info.ID, "err:", fwdErr)
+	}
+	return fmt.Errorf("%w: %w", err, asynq.RevokeTask)
+}
+
+// enqueueCopy 投递当前任务的副本，沿用原任务的队列、超时与保留时间，返回副本的任务 ID
+func (a *AsynqServer) enqueueCopy(ctx context.Context, task *asynq.Task, info *MessageInfo, delay time.Duration) (string, error) {
+	client, insp := a.requeuer()
+	taskID, _ := asynq.GetTaskID(ctx)
+	origin, err := insp.GetTaskInfo(info.Queue, taskID)
+	if err != nil {
+		return "", err
+	}
+	headers, body := decodeEnvelope(task.Payload())
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers[headerRequeueID] = info.ID
+	headers[headerRequeueRetried] = strconv.Itoa(info.Retried)
+	payload, err := encodeEnvelope(headers, body)
+	if err != nil {
+		return "", errors.Wrap(MessageEncodeFailed, err.Error())
+	}
+	next := uuid.NewString()
+	opts := append(copyOptions(origin),
+		asynq.TaskID(next),
+		asynq.Queue(info.Queue),
+		asynq.MaxRetry(0),
+		asynq.ProcessIn(delay),
+	)
+	if _, err := client.EnqueueContext(ctx, asynq.NewTask(task.Type(), payload), opts...); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// copyOptions 返回投递副本时沿用的原任务超时、截止时间与保留时间
+func copyOptions(origin *asynq.TaskInfo) []asynq.Option {
+	var opts []asynq.Option
+	if origin.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(origin.Timeout))
+	}
+	if !origin.Deadline.IsZero() {
+		opts = append(opts, asynq.Deadline(origin.Deadline))
+	}
+	if origin.Retention > 0 {
+		opts = append(opts, asynq.Retention(origin.Retention))
+	}
+	return opts
+}
+
+// requeuer 返回用于重新投递的客户端与检查器
+func (a *AsynqServer) requeuer() (*asynq.Client, *asynq.Inspector) {
+	a.requeueOnce.Do(func() {
+		a.requeueClient = asynq.NewClientFromRedisClient(a.redis())
+		a.insp = asynq.NewInspectorFromRedisClient(a.redis())
+	})
+	return a.requeueClient, a.insp
+}
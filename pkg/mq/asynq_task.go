@@ -0,0 +1,201 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	asynqTaskPrefix = "mq:task:"     // 任务进度与取消标记前缀
+	asynqTaskTTL    = 24 * time.Hour // 任务进度与取消标记保留时间
+)
+
+// asynqTaskStore 任务进度与取消标记存储
+type asynqTaskStore struct {
+	rdb redis.UniversalClient
+}
+
+func (s *asynqTaskStore) progressKey(id string) string {
+	return asynqTaskPrefix + id + ":progress"
+}
+
+func (s *asynqTaskStore) canceledKey(id string) string {
+	return asynqTaskPrefix + id + ":canceled"
+}
+
+func (s *asynqTaskStore) forwardKey(id string) string {
+	return asynqTaskPrefix + id + ":forward"
+}
+
+func (s *asynqTaskStore) setProgress(ctx context.Context, id string, p *TaskProgress) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, s.progressKey(id), data, asynqTaskTTL).Err()
+}
+
+func (s *asynqTaskStore) progress(ctx context.Context, id string) (*TaskProgress, error) {
+	data, err := s.rdb.Get(ctx, s.progressKey(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	p := &TaskProgress{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *asynqTaskStore) markCanceled(ctx context.Context, id string) error {
+	return s.rdb.Set(ctx, s.canceledKey(id), 1, asynqTaskTTL).Err()
+}
+
+func (s *asynqTaskStore) canceled(ctx context.Context, id string) bool {
+	n, err := s.rdb.Exists(ctx, s.canceledKey(id)).Result()
+	return err == nil && n > 0
+}
+
+// setForward 记录消息重新投递后的任务 ID
+func (s *asynqTaskStore) setForward(ctx context.Context, id, next string) error {
+	return s.rdb.Set(ctx, s.forwardKey(id), next, asynqTaskTTL).Err()
+}
+
+// forward 返回消息重新投递后的任务 ID，未重新投递时返回空
+func (s *asynqTaskStore) forward(ctx context.Context, id string) string {
+	next, _ := s.rdb.Get(ctx, s.forwardKey(id)).Result()
+	return next
+}
+
+// asynqProgressReporter 上报 asynq 任务进度
+type asynqProgressReporter struct {
+	store func() *asynqTaskStore
+	id    string
+}
+
+func (r *asynqProgressReporter) Report(ctx context.Context, percent float64, message string) error {
+	return r.store().setProgress(ctx, r.id, &TaskProgress{
+		Percent:   min(max(percent, 0), 100),
+		Message:   message,
+		UpdatedAt: time.Now(),
+	})
+}
+
+// asynqTaskState 转换 asynq 任务状态
+func asynqTaskState(state asynq.TaskState, canceled bool) TaskState {
+	if canceled && state != asynq.TaskStateCompleted {
+		return TaskStateCanceled
+	}
+	switch state {
+	case asynq.TaskStateActive:
+		return TaskStateActive
+	case asynq.TaskStateRetry:
+		return TaskStateRetry
+	case asynq.TaskStateCompleted:
+		return TaskStateCompleted
+	case asynq.TaskStateArchived:
+		return TaskStateFailed
+	default:
+		return TaskStatePending
+	}
+}
+
+var _ TaskTracker = (*AsynqClient)(nil)
+
+// taskStore 返回任务进度存储
+func (a *AsynqClient) taskStore() *asynqTaskStore {
+	return &asynqTaskStore{rdb: a.redis()}
+}
+
+// TaskStatus 查询任务状态
+// 任务处理成功后需设置 WithRetention 才能查询到结果，否则返回 TaskNotFound
+func (a *AsynqClient) TaskStatus(ctx context.Context, b *MessageConfig, id string) (*TaskStatus, error) {
+	store := a.taskStore()
+	canceled := store.canceled(ctx, id)
+	info, err := a.findTask(ctx, b, id)
+	if err != nil {
+		if errors.Is(err, TaskNotFound) && canceled {
+			return &TaskStatus{ID: id, Key: b.Key, State: TaskStateCanceled}, nil
+		}
+		return nil, err
+	}
+	progress, err := store.progress(ctx, id)
+	if err != nil {
+		a.log.Warn("Asynq 任务进度查询失败,err:", err)
+	}
+	return &TaskStatus{
+		ID:            id,
+		Key:           b.Key,
+		Queue:         info.Queue,
+		State:         asynqTaskState(info.State, canceled),
+		Retried:       info.Retried,
+		MaxRetry:      info.MaxRetry,
+		LastErr:       info.LastErr,
+		Result:        info.Result,
+		Progress:      progress,
+		NextProcessAt: info.NextProcessAt,
+		CompletedAt:   info.CompletedAt,
+	}, nil
+}
+
+// CancelTask 取消任务
+func (a *AsynqClient) CancelTask(ctx context.Context, b *MessageConfig, id string) error {
+	info, err := a.findTask(ctx, b, id)
+	if err != nil {
+		return err
+	}
+	switch info.State {
+	case asynq.TaskStateCompleted, asynq.TaskStateArchived:
+		return errors.Wrapf(TaskNotCancelable, "task %s is %s", id, info.State)
+	}
+	// 先写取消标记，消费端据此判断 context 取消是否来自用户，并停止重试
+	if err := a.taskStore().markCanceled(ctx, id); err != nil {
+		return err
+	}
+	if info.State == asynq.TaskStateActive {
+		return a.inspector().CancelProcessing(info.ID)
+	}
+	if err := a.inspector().DeleteTask(info.Queue, info.ID); err != nil {
+		// 删除前任务恰好开始处理
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return nil
+		}
+		if info, getErr := a.inspector().GetTaskInfo(info.Queue, info.ID); getErr == nil && info.State == asynq.TaskStateActive {
+			return a.inspector().CancelProcessing(info.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// findTask 在所有队列中查找属于该消息配置的任务，消息被重新投递时返回最新的副本
+func (a *AsynqClient) findTask(ctx context.Context, b *MessageConfig, id string) (*asynq.TaskInfo, error) {
+	if next := a.taskStore().forward(ctx, id); next != "" {
+		id = next
+	}
+	queues, err := a.inspector().Queues()
+	if err != nil {
+		return nil, err
+	}
+	for _, queue := range queues {
+		info, err := a.inspector().GetTaskInfo(queue, id)
+		if err != nil {
+			if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if info.Type == b.Metadata[MetaKeyAsynqQueue] {
+			return info, nil
+		}
+	}
+	return nil, errors.Wrapf(TaskNotFound, "task %s", id)
+}
@@ -0,0 +1,209 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/hibiken/asynq"
+)
+
+func newTestAsynqServer() *AsynqServer {
+	return NewAsynqServer(
+		log.DefaultLogger,
+		asynq.RedisClientOpt{Addr: "127.0.0.1:0"},
+		NwDefaultAsynqConfig(),
+		NewDefaultSchedulerOpts(log.DefaultLogger),
+	)
+}
+
+func TestAsynqServerRegister(t *testing.T) {
+	srv := newTestAsynqServer()
+	handle := func(ctx context.Context, msg []byte) error { return nil }
+	b := &MessageConfig{Key: "a", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:a"}}
+
+	if err := srv.ConsumerNormalRegister(b, handle); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	dup := &MessageConfig{Key: "b", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:a"}}
+	if err := srv.ConsumerCronRegister(dup, handle, "@every 1m"); !errors.Is(err, ConsumerAlreadyRegistered) {
+		t.Errorf("err = %v, want ConsumerAlreadyRegistered", err)
+	}
+
+	srv.started = true
+	c := &MessageConfig{Key: "c", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:c"}}
+	if err := srv.ConsumerNormalRegister(c, handle); !errors.Is(err, ServerAlreadyStarted) {
+		t.Errorf("err = %v, want ServerAlreadyStarted", err)
+	}
+}
+
+func TestAsynqServerStopBeforeStart(t *testing.T) {
+	srv := newTestAsynqServer()
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Errorf("Stop before Start should be a no-op, got %v", err)
+	}
+}
+
+func TestAsynqServerCron(t *testing.T) {
+	srv := newTestAsynqServer()
+	b := &MessageConfig{Key: "report", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:report"}}
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	if err := srv.AddCron(b, &CronJob{Name: "bad", Spec: "not a cron"}); !errors.Is(err, CronSpecInvalid) {
+		t.Errorf("err = %v, want CronSpecInvalid", err)
+	}
+	if err := srv.AddCron(b, &CronJob{Name: "daily", Spec: "0 9 * * *", Location: shanghai, Jitter: time.Minute}); err != nil {
+		t.Fatalf("AddCron failed: %v", err)
+	}
+	if err := srv.AddCron(b, &CronJob{Name: "daily", Spec: "@every 1m"}); !errors.Is(err, CronAlreadyExists) {
+		t.Errorf("err = %v, want CronAlreadyExists", err)
+	}
+
+	entries := srv.ListCrons()
+	if len(entries) != 1 || entries[0].Location != "Asia/Shanghai" || entries[0].Key != "report" || entries[0].Paused {
+		t.Fatalf("entries = %+v", entries[0])
+	}
+	if next := entries[0].Next.In(shanghai); next.Hour() != 9 || next.Minute() != 0 {
+		t.Errorf("next = %v, want 09:00 Asia/Shanghai", next)
+	}
+
+	if err := srv.PauseCron("daily"); err != nil {
+		t.Fatal(err)
+	}
+	if e := srv.ListCrons()[0]; !e.Paused || !e.Next.IsZero() {
+		t.Errorf("paused entry = %+v", e)
+	}
+	if err := srv.ResumeCron("daily"); err != nil {
+		t.Fatal(err)
+	}
+	if e := srv.ListCrons()[0]; e.Paused || e.Next.IsZero() {
+		t.Errorf("resumed entry = %+v", e)
+	}
+	if err := srv.RemoveCron("daily"); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.PauseCron("daily"); !errors.Is(err, CronNotFound) {
+		t.Errorf("err = %v, want CronNotFound", err)
+	}
+}
+
+// 多实例同一触发时刻只投递一次，任务处理完成并删除后仍不会重复投递
+func TestAsynqCronDedup(t *testing.T) {
+	mr := miniredis.RunT(t)
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	b := &MessageConfig{Key: "report", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:report"}}
+	var jobs []*asynqCronJob
+	for i := 0; i < 2; i++ {
+		srv := NewAsynqServer(log.DefaultLogger, opt, NwDefaultAsynqConfig(), NewDefaultSchedulerOpts(log.DefaultLogger))
+		if err := srv.AddCron(b, &CronJob{Name: "hourly", Spec: "0 * * * *"}); err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, srv.cron.jobs["hourly"])
+	}
+	insp := asynq.NewInspector(opt)
+	defer insp.Close()
+	pending := func() []*asynq.TaskInfo {
+		tasks, err := insp.ListPendingTasks("default")
+		if err != nil && !errors.Is(err, asynq.ErrQueueNotFound) {
+			t.Fatal(err)
+		}
+		return tasks
+	}
+
+	prev := time.Now().Truncate(time.Hour)
+	for _, j := range jobs {
+		j.publish(context.Background(), prev)
+	}
+	tasks := pending()
+	if len(tasks) != 1 {
+		t.Fatalf("pending tasks = %d, want 1", len(tasks))
+	}
+	// 锁保留到下一次触发之后
+	if ttl := mr.TTL(fmt.Sprintf("%shourly:%d", asynqCronLockPrefix, prev.Unix())); ttl < time.Hour {
+		t.Fatalf("lock ttl = %v, want at least the cron interval", ttl)
+	}
+	if err := insp.DeleteTask("default", tasks[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	jobs[1].publish(context.Background(), prev)
+	if n := len(pending()); n != 0 {
+		t.Fatalf("tick already published, pending tasks = %d", n)
+	}
+	jobs[1].publish(context.Background(), prev.Add(time.Hour))
+	if n := len(pending()); n != 1 {
+		t.Fatalf("next tick pending tasks = %d, want 1", n)
+	}
+}
+
+// startTestAsynq 基于 miniredis 启动 asynq 服务端，返回连接配置
+func startTestAsynq(t *testing.T, register func(srv *AsynqServer)) asynq.RedisClientOpt {
+	t.Helper()
+	opt := asynq.RedisClientOpt{Addr: miniredis.RunT(t).Addr()}
+	cfg := NwDefaultAsynqConfig()
+	cfg.DelayedTaskCheckInterval = 20 * time.Millisecond
+	cfg.LogLevel = asynq.ErrorLevel
+	srv := NewAsynqServer(log.DefaultLogger, opt, cfg, NewDefaultSchedulerOpts(log.DefaultLogger))
+	register(srv)
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.Stop(context.Background()) })
+	return opt
+}
+
+func TestAsynqRequeueWithoutRetries(t *testing.T) {
+	b := &MessageConfig{Key: "requeue", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:requeue"}}
+	seen := make(chan *MessageInfo, 2)
+	var calls atomic.Int32
+	opt := startTestAsynq(t, func(srv *AsynqServer) {
+		_ = srv.ConsumerNormalRegister(b, func(ctx context.Context, msg []byte) error {
+			info, _ := MessageFromContext(ctx)
+			seen <- info
+			if calls.Add(1) == 1 {
+				return Requeue(MessageInProgress, 10*time.Millisecond)
+			}
+			return nil
+		})
+	})
+
+	client := NewAsynqClient(log.DefaultLogger, opt)
+	id, err := client.Publish(context.Background(), b, []byte("hi"), WithMaxRetry(0), WithHeader("tenant", "t1"), WithRetention(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case info := <-seen:
+			if info.ID != id || info.Headers["tenant"] != "t1" || info.Retried != 0 || info.MaxRetry != 0 {
+				t.Fatalf("attempt %d: unexpected message info %+v", i, info)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("attempt %d: message was not redelivered", i)
+		}
+	}
+
+	insp := asynq.NewInspector(opt)
+	defer insp.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := client.TaskStatus(context.Background(), b, id)
+		if err == nil && status.State == TaskStateCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task status = %+v, err = %v", status, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if q, err := insp.GetQueueInfo("default"); err != nil || q.Archived != 0 {
+		t.Fatalf("requeued message should not be archived, queue = %+v err = %v", q, err)
+	}
+}
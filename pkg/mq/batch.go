@@ -0,0 +1,253 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BatchHandle 批量消费业务方法
+// 返回 *BatchError 时仅重试其中失败的消息，返回其它错误时整批重试
+type BatchHandle func(ctx context.Context, msgs [][]byte) error
+
+// BatchError 批量消费的部分失败，Failed 的键为 msgs 中的下标
+type BatchError struct {
+	Failed map[int]error
+}
+
+// NewBatchError 创建部分失败错误
+func NewBatchError(failed map[int]error) *BatchError {
+	return &BatchError{Failed: failed}
+}
+
+func (e *BatchError) Error() string {
+	idx := make([]int, 0, len(e.Failed))
+	for i := range e.Failed {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	parts := make([]string, 0, len(idx))
+	for _, i := range idx {
+		parts = append(parts, fmt.Sprintf("%d: %v", i, e.Failed[i]))
+	}
+	return fmt.Sprintf("batch partially failed (%d items): %s", len(idx), strings.Join(parts, "; "))
+}
+
+// BatchOptions 批量消费参数，可通过 MessageConfig.Batch 配置默认值
+type BatchOptions struct {
+	Size int           `json:"size" yaml:"size"` // 单批最大消息数，默认 100
+	Wait time.Duration `json:"wait" yaml:"wait"` // 首条消息到达后最长等待时间，默认 1s
+}
+
+// BatchOption 批量消费选项
+type BatchOption func(*BatchOptions)
+
+// WithBatchSize 设置单批最大消息数
+func WithBatchSize(n int) BatchOption {
+	return func(o *BatchOptions) {
+		o.Size = n
+	}
+}
+
+// WithBatchWait 设置单批最长等待时间
+func WithBatchWait(d time.Duration) BatchOption {
+	return func(o *BatchOptions) {
+		o.Wait = d
+	}
+}
+
+// newBatchOptions 合并 MessageConfig.Batch 与批量消费选项
+func newBatchOptions(b *MessageConfig, opts ...BatchOption) BatchOptions {
+	o := BatchOptions{Size: 100, Wait: time.Second}
+	if b != nil && b.Batch != nil {
+		if b.Batch.Size > 0 {
+			o.Size = b.Batch.Size
+		}
+		if b.Batch.Wait > 0 {
+			o.Wait = b.Batch.Wait
+		}
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Size <= 0 {
+		o.Size = 1
+	}
+	return o
+}
+
+// BatchServer 由消息队列聚合批次的服务端，等待聚合的消息不占用消费者并发
+type BatchServer interface {
+	// ConsumerBatchRegister 注册一个批量消费者，仅允许在启动前调用
+	ConsumerBatchRegister(b *MessageConfig, h BatchHandle, opts ...BatchOption) error
+}
+
+// RegisterBatch 为 server 注册一个批量消费者
+// server 实现 BatchServer 时由其聚合批次（如 AsynqServer 使用 asynq 的任务分组），否则使用 Batch 在进程内聚合
+func RegisterBatch(server Server, b *MessageConfig, h BatchHandle, opts ...BatchOption) error {
+	if s, ok := server.(BatchServer); ok {
+		return s.ConsumerBatchRegister(b, h, opts...)
+	}
+	return server.ConsumerNormalRegister(b, newBatcher(h, newBatchOptions(b, opts...)).add)
+}
+
+// batchItem 等待聚合的消息
+type batchItem struct {
+	ctx  context.Context
+	msg  []byte
+	done chan error
+}
+
+// batcher 在进程内聚合并发到达的消息
+type batcher struct {
+	handle  BatchHandle
+	opts    BatchOptions
+	mu      sync.Mutex
+	pending []*batchItem
+	timer   *time.Timer
+}
+
+// Batch 将批量消费方法转换为 Handle
+// 消息在进程内按数量或时间窗口聚合后调用一次 h，每条消息的 Handle 会阻塞至所在批次完成并返回自己的结果，
+// 因此失败的消息由消息队列按原有策略单独重试；等待中的消息占用消费者的并发，单批实际大小不超过消费者并发数
+func Batch(h BatchHandle, opts ...BatchOption) Handle {
+	return newBatcher(h, newBatchOptions(nil, opts...)).add
+}
+
+func newBatcher(h BatchHandle, o BatchOptions) *batcher {
+	return &batcher{handle: h, opts: o}
+}
+
+// add 加入当前批次并等待批次完成，ctx 结束时返回 ctx 的错误，尚未处理的消息会从批次中移除
+func (b *batcher) add(ctx context.Context, msg []byte) error {
+	item := &batchItem{ctx: ctx, msg: msg, done: make(chan error, 1)}
+	b.mu.Lock()
+	b.pending = append(b.pending, item)
+	var items []*batchItem
+	if len(b.pending) >= b.opts.Size {
+		items = b.take()
+	} else if len(b.pending) == 1 {
+		b.timer = time.AfterFunc(b.opts.Wait, b.flush)
+	}
+	b.mu.Unlock()
+	if items != nil {
+		go b.run(items)
+	}
+	select {
+	case err := <-item.done:
+		return err
+	case <-ctx.Done():
+		b.remove(item)
+		return ctx.Err()
+	}
+}
+
+// remove 从待处理的批次中移除消息
+func (b *batcher) remove(item *batchItem) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, p := range b.pending {
+		if p == item {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			break
+		}
+	}
+	if len(b.pending) == 0 && b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+// take 取出当前批次，调用方需持有锁
+func (b *batcher) take() []*batchItem {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	items := b.pending
+	b.pending = nil
+	return items
+}
+
+// flush 时间窗口到期
+func (b *batcher) flush() {
+	b.mu.Lock()
+	items := b.take()
+	b.mu.Unlock()
+	if len(items) > 0 {
+		b.run(items)
+	}
+}
+
+// run 调用批量消费方法并分发每条消息的结果
+func (b *batcher) run(items []*batchItem) {
+	ctx, cancel := batchContext(items)
+	defer cancel()
+	msgs := make([][]byte, len(items))
+	for i, item := range items {
+		msgs[i] = item.msg
+	}
+	err := callBatch(ctx, b.handle, msgs)
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		for i, item := range items {
+			item.done <- batchErr.Failed[i]
+		}
+		return
+	}
+	for _, item := range items {
+		item.done <- err
+	}
+}
+
+// batchContext 批次的 context，携带首条消息的值，截止时间取各消息中最早的，所有消息的 context 都结束时取消
+func batchContext(items []*batchItem) (context.Context, context.CancelFunc) {
+	var deadline time.Time
+	for _, item := range items {
+		if d, ok := item.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	var (
+		parent = context.WithoutCancel(items[0].ctx)
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if deadline.IsZero() {
+		ctx, cancel = context.WithCancel(parent)
+	} else {
+		ctx, cancel = context.WithDeadline(parent, deadline)
+	}
+	var remaining atomic.Int32
+	remaining.Store(int32(len(items)))
+	stops := make([]func() bool, len(items))
+	for i, item := range items {
+		stops[i] = context.AfterFunc(item.ctx, func() {
+			if remaining.Add(-1) == 0 {
+				cancel()
+			}
+		})
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel()
+	}
+}
+
+// callBatch 调用批量消费方法并隔离 panic
+func callBatch(ctx context.Context, h BatchHandle, msgs [][]byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("batch handle panic: %v", r)
+		}
+	}()
+	return h(ctx, msgs)
+}
@@ -0,0 +1,216 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/hibiken/asynq"
+)
+
+func TestBatchSize(t *testing.T) {
+	var calls [][]string
+	var mu sync.Mutex
+	h := Batch(func(ctx context.Context, msgs [][]byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batch := make([]string, len(msgs))
+		for i, m := range msgs {
+			batch[i] = string(m)
+		}
+		calls = append(calls, batch)
+		failed := map[int]error{}
+		for i, m := range msgs {
+			if string(m) == "bad" {
+				failed[i] = errors.New("bad message")
+			}
+		}
+		if len(failed) > 0 {
+			return NewBatchError(failed)
+		}
+		return nil
+	}, WithBatchSize(3), WithBatchWait(time.Minute))
+
+	results := make(map[string]error)
+	var wg sync.WaitGroup
+	var rmu sync.Mutex
+	for _, m := range []string{"a", "bad", "c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := h(context.Background(), []byte(m))
+			rmu.Lock()
+			results[m] = err
+			rmu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(calls) != 1 || len(calls[0]) != 3 {
+		t.Fatalf("expected one batch of 3, got %v", calls)
+	}
+	if results["a"] != nil || results["c"] != nil || results["bad"] == nil {
+		t.Fatalf("unexpected results: %v", results)
+	}
+}
+
+func TestBatchWait(t *testing.T) {
+	calls := 0
+	h := Batch(func(ctx context.Context, msgs [][]byte) error {
+		calls++
+		if len(msgs) != 1 {
+			t.Errorf("expected 1 message, got %d", len(msgs))
+		}
+		return errors.New("boom")
+	}, WithBatchSize(10), WithBatchWait(10*time.Millisecond))
+
+	start := time.Now()
+	if err := h(context.Background(), []byte("x")); err == nil || err.Error() != "boom" {
+		t.Fatalf("expected whole batch error, got %v", err)
+	}
+	if time.Since(start) < 10*time.Millisecond || calls != 1 {
+		t.Fatalf("batch flushed too early or not once: calls=%d", calls)
+	}
+}
+
+func TestBatchPanic(t *testing.T) {
+	h := Batch(func(ctx context.Context, msgs [][]byte) error {
+		panic("oops")
+	}, WithBatchSize(1))
+	if err := h(context.Background(), []byte("x")); err == nil {
+		t.Fatal("expected panic converted to error")
+	}
+}
+
+func TestBatchContextDone(t *testing.T) {
+	called := false
+	h := Batch(func(ctx context.Context, msgs [][]byte) error {
+		called = true
+		return nil
+	}, WithBatchSize(10), WithBatchWait(50*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := h(ctx, []byte("x")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 40*time.Millisecond {
+		t.Fatal("handle should return when its context is done")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if called {
+		t.Fatal("canceled message should be removed from the batch")
+	}
+}
+
+func TestBatchEventBusSize(t *testing.T) {
+	bus := newTestEventBus(t, WithEventBusWorkers(2))
+	err := RegisterBatch(bus, &MessageConfig{Key: "events"}, func(ctx context.Context, msgs [][]byte) error {
+		return nil
+	}, WithBatchSize(10))
+	if !errors.Is(err, BatchSizeTooLarge) {
+		t.Fatalf("expected BatchSizeTooLarge, got %v", err)
+	}
+}
+
+// asynq 由任务分组聚合批次，部分失败时仅失败的消息重试，重试耗尽后只有失败的消息进入死信
+func TestBatchAsynq(t *testing.T) {
+	b := &MessageConfig{
+		Key:      "clicks",
+		Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:clicks"},
+		Retry:    &RetryPolicy{MaxRetry: 1, Backoff: BackoffFixed, Delay: 10 * time.Millisecond},
+		Batch:    &BatchOptions{Size: 3, Wait: time.Second},
+	}
+	var (
+		mu      sync.Mutex
+		batches [][]string
+	)
+	calls := make(chan struct{}, 10)
+	opt := startTestAsynq(t, func(srv *AsynqServer) {
+		err := RegisterBatch(srv, b, func(ctx context.Context, msgs [][]byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			batch := make([]string, len(msgs))
+			failed := map[int]error{}
+			for i, m := range msgs {
+				batch[i] = string(m)
+				if string(m) == "bad" {
+					failed[i] = errors.New("bad click")
+				}
+			}
+			batches = append(batches, batch)
+			calls <- struct{}{}
+			if len(failed) > 0 {
+				return NewBatchError(failed)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	client := NewAsynqClient(log.DefaultLogger, opt)
+	for _, m := range []string{"a", "bad", "c"} {
+		if _, err := client.Publish(context.Background(), b, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insp := asynq.NewInspector(opt)
+	defer insp.Close()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		q, err := insp.GetQueueInfo("default")
+		if err == nil && q.Archived == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("failed message was not archived, queue = %+v err = %v batches = %v", q, err, batches)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	counts := map[string]int{}
+	for _, batch := range batches {
+		if len(batch) > 3 {
+			t.Fatalf("batch exceeds size: %v", batch)
+		}
+		for _, m := range batch {
+			counts[m]++
+		}
+	}
+	if counts["a"] != 1 || counts["c"] != 1 || counts["bad"] != 2 {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+	archived, err := insp.ListArchivedTasks("default")
+	if err != nil || len(archived) != 1 {
+		t.Fatalf("archived = %v err = %v", archived, err)
+	}
+	_, payload := decodeEnvelope(archived[0].Payload)
+	payloads, ok := decodeBatch(payload)
+	if !ok || len(payloads) != 1 || !slices.Equal(payloads[0], []byte("bad")) {
+		t.Fatalf("dead letter should only contain the failed message, got %q", payloads)
+	}
+}
+
+// 日志中不输出信封与聚合批次的二进制内容
+func TestLogBody(t *testing.T) {
+	payload, err := encodeEnvelope(map[string]string{"k": "v"}, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, body := decodeEnvelope(payload)
+	if headers["k"] != "v" || logBody(body) != "hello" {
+		t.Fatalf("headers = %v, body = %q", headers, logBody(body))
+	}
+	if got := logBody(encodeBatch([][]byte{payload, payload})); got != "batch of 2 messages" {
+		t.Fatalf("logBody(batch) = %q", got)
+	}
+}
@@ -2,7 +2,13 @@ package mq
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+
+	conf "github.com/fzf-labs/kratos-contrib/api/conf/v1"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 // MQType 消息队列类型
@@ -29,10 +35,38 @@ const (
 	MetaKeyRabbitMQQueue      MetaKey = "rabbitmq_queue"       // rabbitmq 队列
 )
 
+// requiredMetaKeys 各消息队列类型必需的元数据键
+var requiredMetaKeys = map[MQType][]MetaKey{
+	MQTypeAsynq:    {MetaKeyAsynqQueue},
+	MQTypeRocketMQ: {MetaKeyRocketMQTag, MetaKeyRocketMQGroupId},
+	MQTypeKafka:    {MetaKeyKafkaGroupId},
+	MQTypeRabbitMQ: {MetaKeyRabbitMQExchange, MetaKeyRabbitMQRoutingKey, MetaKeyRabbitMQQueue},
+}
+
 // MessageConfig 消息配置结构体
 type MessageConfig struct {
-	Key      string             `json:"key"`
-	Metadata map[MetaKey]string `json:"metadata"`
+	Key      string             `json:"key" yaml:"key"`
+	Metadata map[MetaKey]string `json:"metadata" yaml:"metadata"`
+	Retry    *RetryPolicy       `json:"retry" yaml:"retry"` // 重试策略，nil 使用消息队列默认值
+	Limit    *LimitPolicy       `json:"limit" yaml:"limit"` // 消费限制，nil 表示不限制
+	Batch    *BatchOptions      `json:"batch" yaml:"batch"` // 批量消费参数，asynq 生产端据此将消息投递到任务分组
+}
+
+// Validate 校验配置是否包含 mqType 必需的元数据
+func (m *MessageConfig) Validate(mqType MQType) error {
+	if m.Key == "" {
+		return errors.Wrap(MetadataMissing, "key is empty")
+	}
+	var missing []string
+	for _, k := range requiredMetaKeys[mqType] {
+		if m.Metadata[k] == "" {
+			missing = append(missing, string(k))
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Wrapf(MetadataMissing, "key %s, type %s: %s", m.Key, mqType, strings.Join(missing, ","))
+	}
+	return nil
 }
 
 // MessageConfigManager 配置管理器
@@ -42,6 +76,21 @@ type MessageConfigManager struct {
 	Configs map[string]*MessageConfig `json:"configs"`
 }
 
+// messageConfigSection 配置文件中的消息配置段
+//
+//	type: asynq
+//	configs:
+//	  order_created:
+//	    metadata:
+//	      asynq_queue: order:created
+//	    retry:
+//	      max_retry: 5
+//	      delay: 1s
+type messageConfigSection struct {
+	Type    MQType                    `yaml:"type"`
+	Configs map[string]*MessageConfig `yaml:"configs"`
+}
+
 // NewMessageConfigManager 创建配置管理器
 func NewMessageConfigManager(mqType MQType) *MessageConfigManager {
 	return &MessageConfigManager{
@@ -50,13 +99,117 @@ func NewMessageConfigManager(mqType MQType) *MessageConfigManager {
 	}
 }
 
-// Register 注册配置
+// Register 注册配置，key 重复时 panic
 func (c *MessageConfigManager) Register(config *MessageConfig) *MessageConfig {
+	if _, err := c.TryRegister(config); err != nil {
+		panic(fmt.Sprintf("key %s is exsit, please change one", config.Key))
+	}
+	return config
+}
+
+// TryRegister 注册配置，key 重复时返回 KeyAlreadyExists
+func (c *MessageConfigManager) TryRegister(config *MessageConfig) (*MessageConfig, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if _, exists := c.Configs[config.Key]; exists {
-		panic(fmt.Sprintf("key %s is exsit, please change one", config.Key))
+		return nil, errors.Wrapf(KeyAlreadyExists, "key %s", config.Key)
 	}
 	c.Configs[config.Key] = config
+	return config, nil
+}
+
+// Get 获取配置，不存在时返回 KeyNotFound
+func (c *MessageConfigManager) Get(key string) (*MessageConfig, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	config, ok := c.Configs[key]
+	if !ok {
+		return nil, errors.Wrapf(KeyNotFound, "key %s", key)
+	}
+	return config, nil
+}
+
+// MustGet 获取配置，不存在时 panic
+func (c *MessageConfigManager) MustGet(key string) *MessageConfig {
+	config, err := c.Get(key)
+	if err != nil {
+		panic(err)
+	}
 	return config
 }
+
+// List 按 key 排序列出所有配置
+func (c *MessageConfigManager) List() []*MessageConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make([]*MessageConfig, 0, len(c.Configs))
+	for _, config := range c.Configs {
+		res = append(res, config)
+	}
+	slices.SortFunc(res, func(a, b *MessageConfig) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return res
+}
+
+// Validate 校验所有配置是否包含消息队列类型必需的元数据
+func (c *MessageConfigManager) Validate() error {
+	for _, config := range c.List() {
+		if err := config.Validate(c.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadYAML 从 YAML 配置段批量加载配置，格式见 messageConfigSection
+// 配置段的 type 不为空时必须与管理器类型一致；加载前会校验全部配置，任意一条失败则不注册
+func (c *MessageConfigManager) LoadYAML(data []byte) error {
+	var section messageConfigSection
+	if err := yaml.Unmarshal(data, &section); err != nil {
+		return err
+	}
+	if section.Type != "" && section.Type != c.Type {
+		return errors.Errorf("mq type mismatch: manager %s, config %s", c.Type, section.Type)
+	}
+	keys := make([]string, 0, len(section.Configs))
+	for key, config := range section.Configs {
+		if config == nil {
+			config = &MessageConfig{}
+			section.Configs[key] = config
+		}
+		if config.Key == "" {
+			config.Key = key
+		}
+		if err := config.Validate(c.Type); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if _, exists := c.Configs[section.Configs[key].Key]; exists {
+			return errors.Wrapf(KeyAlreadyExists, "key %s", section.Configs[key].Key)
+		}
+	}
+	for _, key := range keys {
+		config := section.Configs[key]
+		c.Configs[config.Key] = config
+	}
+	return nil
+}
+
+// LoadBusiness 从 Bootstrap.Business 的 name 配置段批量加载配置，格式同 LoadYAML
+func (c *MessageConfigManager) LoadBusiness(bc *conf.Bootstrap, name string) error {
+	s, ok := bc.GetBusiness()[name]
+	if !ok {
+		return errors.Wrapf(KeyNotFound, "business section %s", name)
+	}
+	data, err := yaml.Marshal(s.AsMap())
+	if err != nil {
+		return err
+	}
+	return c.LoadYAML(data)
+}
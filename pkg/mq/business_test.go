@@ -0,0 +1,98 @@
+package mq
+
+import (
+	"testing"
+	"time"
+
+	conf "github.com/fzf-labs/kratos-contrib/api/conf/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestMessageConfigManagerGet(t *testing.T) {
+	m := NewMessageConfigManager(MQTypeAsynq)
+	cfg := m.Register(&MessageConfig{Key: "order_created", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "order:created"}})
+
+	got, err := m.Get("order_created")
+	if err != nil || got != cfg {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+	if _, err := m.Get("missing"); !errors.Is(err, KeyNotFound) {
+		t.Fatalf("expected KeyNotFound, got %v", err)
+	}
+	if _, err := m.TryRegister(&MessageConfig{Key: "order_created"}); !errors.Is(err, KeyAlreadyExists) {
+		t.Fatalf("expected KeyAlreadyExists, got %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("MustGet should panic on missing key")
+			}
+		}()
+		m.MustGet("missing")
+	}()
+}
+
+func TestMessageConfigManagerLoadYAML(t *testing.T) {
+	m := NewMessageConfigManager(MQTypeAsynq)
+	m.Register(&MessageConfig{Key: "a_existing", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "existing"}})
+	err := m.LoadYAML([]byte(`
+type: asynq
+configs:
+  order_created:
+    metadata:
+      asynq_queue: order:created
+    retry:
+      max_retry: 5
+      backoff: fixed
+      delay: 2s
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := m.MustGet("order_created")
+	if cfg.Metadata[MetaKeyAsynqQueue] != "order:created" || cfg.Retry.MaxRetry != 5 || cfg.Retry.Delay != 2*time.Second {
+		t.Fatalf("unexpected config: %+v %+v", cfg, cfg.Retry)
+	}
+	list := m.List()
+	if len(list) != 2 || list[0].Key != "a_existing" || list[1].Key != "order_created" {
+		t.Fatalf("unexpected list: %v", list)
+	}
+
+	if err := m.LoadYAML([]byte("configs:\n  bad:\n    metadata: {}\n")); !errors.Is(err, MetadataMissing) {
+		t.Fatalf("expected MetadataMissing, got %v", err)
+	}
+	if err := m.LoadYAML([]byte("type: kafka\n")); err == nil {
+		t.Fatal("expected type mismatch error")
+	}
+	if err := m.LoadYAML([]byte("configs:\n  order_created:\n    metadata:\n      asynq_queue: x\n")); !errors.Is(err, KeyAlreadyExists) {
+		t.Fatalf("expected KeyAlreadyExists, got %v", err)
+	}
+}
+
+func TestMessageConfigManagerLoadBusiness(t *testing.T) {
+	s, err := structpb.NewStruct(map[string]any{
+		"configs": map[string]any{
+			"user_registered": map[string]any{
+				"metadata": map[string]any{"rocketmq_tag": "user", "rocketmq_group_id": "g1"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc := &conf.Bootstrap{Business: map[string]*structpb.Struct{"mq": s}}
+	m := NewMessageConfigManager(MQTypeRocketMQ)
+	if err := m.LoadBusiness(bc, "mq"); err != nil {
+		t.Fatal(err)
+	}
+	if m.MustGet("user_registered").Metadata[MetaKeyRocketMQGroupId] != "g1" {
+		t.Fatal("unexpected metadata")
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.LoadBusiness(bc, "missing"); !errors.Is(err, KeyNotFound) {
+		t.Fatalf("expected KeyNotFound, got %v", err)
+	}
+}
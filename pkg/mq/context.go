@@ -0,0 +1,26 @@
+package mq
+
+import "context"
+
+// MessageInfo 消费中的消息信息
+type MessageInfo struct {
+	ID       string            // 消息 ID
+	Key      string            // 所属 MessageConfig.Key
+	Queue    string            // 所在队列
+	Retried  int               // 已重试次数
+	MaxRetry int               // 最大重试次数
+	Headers  map[string]string // 消息头
+}
+
+type messageInfoKey struct{}
+
+// NewMessageContext 将消息信息放入 context
+func NewMessageContext(ctx context.Context, info *MessageInfo) context.Context {
+	return context.WithValue(ctx, messageInfoKey{}, info)
+}
+
+// MessageFromContext 从 context 中获取消息信息
+func MessageFromContext(ctx context.Context) (*MessageInfo, bool) {
+	info, ok := ctx.Value(messageInfoKey{}).(*MessageInfo)
+	return info, ok
+}
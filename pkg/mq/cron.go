@@ -0,0 +1,42 @@
+package mq
+
+import (
+	"context"
+	"time"
+)
+
+// CronJob 定时任务
+type CronJob struct {
+	Name        string                                    // 任务名称，全局唯一
+	Spec        string                                    // cron 表达式，支持 "@every 1m" 等描述符
+	Location    *time.Location                            // 时区，nil 使用调度器默认时区
+	Payload     []byte                                    // 静态消息体
+	PayloadFunc func(ctx context.Context) ([]byte, error) // 每次触发时生成消息体，优先于 Payload
+	Jitter      time.Duration                             // 随机延迟上限，用于打散同一时刻触发的任务
+}
+
+// CronEntry 定时任务状态
+type CronEntry struct {
+	Name     string    `json:"name"`     // 任务名称
+	Key      string    `json:"key"`      // 所属 MessageConfig.Key
+	Spec     string    `json:"spec"`     // cron 表达式
+	Location string    `json:"location"` // 时区
+	Jitter   string    `json:"jitter"`   // 随机延迟上限
+	Paused   bool      `json:"paused"`   // 是否已暂停
+	Next     time.Time `json:"next"`     // 下次触发时间，暂停或未启动时为零值
+	Prev     time.Time `json:"prev"`     // 上次触发时间
+}
+
+// CronManager 定时任务管理，支持运行时增删与暂停
+type CronManager interface {
+	// AddCron 添加定时任务
+	AddCron(b *MessageConfig, job *CronJob) error
+	// PauseCron 暂停定时任务
+	PauseCron(name string) error
+	// ResumeCron 恢复定时任务
+	ResumeCron(name string) error
+	// RemoveCron 删除定时任务
+	RemoveCron(name string) error
+	// ListCrons 列出所有定时任务
+	ListCrons() []*CronEntry
+}
@@ -0,0 +1,30 @@
+package mq
+
+import (
+	"context"
+	"time"
+)
+
+// DeadLetter 死信消息
+type DeadLetter struct {
+	ID       string    `json:"id"`        // 消息 ID
+	Key      string    `json:"key"`       // 所属 MessageConfig.Key
+	Queue    string    `json:"queue"`     // 所在队列
+	Payload  []byte    `json:"payload"`   // 消息体
+	Retried  int       `json:"retried"`   // 已重试次数
+	MaxRetry int       `json:"max_retry"` // 最大重试次数
+	LastErr  string    `json:"last_err"`  // 最后一次失败原因
+	FailedAt time.Time `json:"failed_at"` // 最后一次失败时间
+}
+
+// DeadLetterQueue 死信队列管理
+type DeadLetterQueue interface {
+	// ListDeadLetters 分页列出死信消息，page 从 1 开始
+	ListDeadLetters(ctx context.Context, b *MessageConfig, page, size int) ([]*DeadLetter, error)
+	// GetDeadLetter 查看一条死信消息
+	GetDeadLetter(ctx context.Context, b *MessageConfig, id string) (*DeadLetter, error)
+	// ReplayDeadLetter 重新投递一条死信消息
+	ReplayDeadLetter(ctx context.Context, b *MessageConfig, id string) error
+	// PurgeDeadLetters 清空死信消息，返回删除数量
+	PurgeDeadLetters(ctx context.Context, b *MessageConfig) (int, error)
+}
@@ -0,0 +1,46 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// envelopeMagic 消息信封前缀
+// 部分消息队列（如 asynq）不支持消息头，消息头与消息体一起编码；
+// 以 0x00 开头的 JSON/protobuf 消息体不存在，可安全区分是否带信封
+var envelopeMagic = []byte("\x00mq\x01")
+
+// encodeEnvelope 编码消息头与消息体，无消息头时原样返回消息体
+func encodeEnvelope(headers map[string]string, body []byte) ([]byte, error) {
+	if len(headers) == 0 {
+		return body, nil
+	}
+	h, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, len(envelopeMagic)+binary.MaxVarintLen64+len(h)+len(body))
+	buf = append(buf, envelopeMagic...)
+	buf = binary.AppendUvarint(buf, uint64(len(h)))
+	buf = append(buf, h...)
+	buf = append(buf, body...)
+	return buf, nil
+}
+
+// decodeEnvelope 解码消息头与消息体，不带信封时消息头为 nil
+func decodeEnvelope(payload []byte) (map[string]string, []byte) {
+	if !bytes.HasPrefix(payload, envelopeMagic) {
+		return nil, payload
+	}
+	rest := payload[len(envelopeMagic):]
+	n, size := binary.Uvarint(rest)
+	if size <= 0 || uint64(len(rest)-size) < n {
+		return nil, payload
+	}
+	var headers map[string]string
+	if err := json.Unmarshal(rest[size:size+int(n)], &headers); err != nil {
+		return nil, payload
+	}
+	return headers, rest[size+int(n):]
+}
@@ -9,4 +9,30 @@ var (
 	DelayedMessageDeliveryFailed = errors.New("delayed message delivery failed")
 	CronMessageDeliveryFailed    = errors.New("cron message delivery failed")
 	DelayLevelError              = errors.New("rocketmq delay level error")
+	SkipRetry                    = errors.New("message should not be retried")
+	DeadLetterNotFound           = errors.New("dead letter message not found")
+	MessageEncodeFailed          = errors.New("message encode failed")
+	MessageDecodeFailed          = errors.New("message decode failed")
+	DuplicateMessage             = errors.New("duplicate message")
+	ProducerNotSupported         = errors.New("client does not support context-aware publishing")
+	MessageInProgress            = errors.New("message is being processed by another consumer")
+	ServerAlreadyStarted         = errors.New("mq server already started, consumers must be registered before start")
+	ConsumerAlreadyRegistered    = errors.New("mq consumer already registered")
+	CronSpecInvalid              = errors.New("invalid cron spec")
+	CronAlreadyExists            = errors.New("cron job already exists")
+	CronNotFound                 = errors.New("cron job not found")
+	MetadataMissing              = errors.New("MQ configuration metadata missing")
+	ConsumerThrottled            = errors.New("consumer is throttled, message will be redelivered")
+	ReplyNotSupported            = errors.New("request/reply is not supported")
+	ReplyTimeout                 = errors.New("waiting for reply timed out")
+	ReplyNotFound                = errors.New("reply not found, the result may have expired")
+	RequestFailed                = errors.New("request failed")
+	EventBusStopped              = errors.New("event bus stopped")
+	TaskNotFound                 = errors.New("task not found")
+	TaskNotCancelable            = errors.New("task is already finished and cannot be canceled")
+	TaskCanceled                 = errors.New("task canceled")
+	MessageOutOfOrder            = errors.New("message is out of order, waiting for earlier messages")
+	BatchSizeTooLarge            = errors.New("batch size exceeds consumer concurrency")
+	AdminAuthRequired            = errors.New("mq admin auth is not configured")
+	TopicClientMissing           = errors.New("topic has no client, publishing is not supported")
 )
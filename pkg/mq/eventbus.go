@@ -0,0 +1,396 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// busMessage 进程内消息
+type busMessage struct {
+	b        *MessageConfig
+	id       string
+	body     []byte
+	headers  map[string]string
+	retried  int
+	maxRetry int
+	timeout  time.Duration
+}
+
+type eventBusOptions struct {
+	workers     int
+	queueSize   int
+	sync        bool
+	middlewares []Middleware
+	sequences   SequenceStore
+	location    *time.Location
+}
+
+// EventBusOption 进程内事件总线选项
+type EventBusOption func(*eventBusOptions)
+
+// WithEventBusWorkers 设置异步模式的工作协程数，默认 10
+func WithEventBusWorkers(n int) EventBusOption {
+	return func(o *eventBusOptions) {
+		o.workers = n
+	}
+}
+
+// WithEventBusQueueSize 设置异步模式的队列长度，队列满时生产方阻塞，默认 1024
+func WithEventBusQueueSize(n int) EventBusOption {
+	return func(o *eventBusOptions) {
+		o.queueSize = n
+	}
+}
+
+// WithEventBusSync 同步模式，非延时消息在生产方协程中处理，并将处理结果返回给生产方，失败不重试
+func WithEventBusSync() EventBusOption {
+	return func(o *eventBusOptions) {
+		o.sync = true
+	}
+}
+
+// WithEventBusMiddleware 为所有消费者添加中间件
+func WithEventBusMiddleware(m ...Middleware) EventBusOption {
+	return func(o *eventBusOptions) {
+		o.middlewares = append(o.middlewares, m...)
+	}
+}
+
+// WithEventBusSequenceStore 设置顺序号存储，默认使用内存存储，可通过 SequenceStore 获取并用于 Ordered 中间件
+func WithEventBusSequenceStore(store SequenceStore) EventBusOption {
+	return func(o *eventBusOptions) {
+		o.sequences = store
+	}
+}
+
+// WithEventBusLocation 设置定时任务的时区，默认本地时区
+func WithEventBusLocation(loc *time.Location) EventBusOption {
+	return func(o *eventBusOptions) {
+		o.location = loc
+	}
+}
+
+// EventBus 进程内事件总线，实现 Client、Producer 与 Server，可与 asynq 等消息队列互相替换
+// 主题为 MessageConfig.Key；支持延时投递、重试（MessageConfig.Retry）、超时与顺序键，
+// 不支持去重、队列与优先级等选项（忽略）；消息仅保存在内存中，停止时未投递的延时消息会丢失
+type EventBus struct {
+	log      *log.Helper
+	opts     eventBusOptions
+	lock     sync.RWMutex
+	handlers map[string]Handle
+	sendLock sync.RWMutex // 生产方持有读锁，关闭队列时持有写锁
+	queue    chan *busMessage
+	done     chan struct{}
+	timers   map[*time.Timer]struct{}
+	cron     *cron.Cron
+	wg       sync.WaitGroup
+	started  bool
+	stopped  bool
+}
+
+var (
+	_ Client           = (*EventBus)(nil)
+	_ Producer         = (*EventBus)(nil)
+	_ Server           = (*EventBus)(nil)
+	_ BatchServer      = (*EventBus)(nil)
+	_ transport.Server = (*EventBus)(nil)
+)
+
+func NewEventBus(logger log.Logger, opts ...EventBusOption) *EventBus {
+	o := eventBusOptions{
+		workers:   10,
+		queueSize: 1024,
+		location:  time.Local,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.sequences == nil {
+		o.sequences = NewMemorySequenceStore()
+	}
+	return &EventBus{
+		log:      log.NewHelper(log.With(logger, "module", "mq.eventbus")),
+		opts:     o,
+		handlers: make(map[string]Handle),
+		queue:    make(chan *busMessage, o.queueSize),
+		done:     make(chan struct{}),
+		timers:   make(map[*time.Timer]struct{}),
+		cron:     cron.New(cron.WithLocation(o.location)),
+	}
+}
+
+// SequenceStore 返回顺序号存储，顺序消费时传给 Ordered
+func (e *EventBus) SequenceStore() SequenceStore {
+	return e.opts.sequences
+}
+
+// ProducerNormalMessage 生产普通消息
+func (e *EventBus) ProducerNormalMessage(b *MessageConfig, msg []byte) error {
+	_, err := e.Publish(context.Background(), b, msg)
+	return err
+}
+
+// ProducerDelayMessage 生产延时消息
+func (e *EventBus) ProducerDelayMessage(b *MessageConfig, msg []byte, t time.Duration) error {
+	_, err := e.Publish(context.Background(), b, msg, WithDelay(t))
+	return err
+}
+
+// Publish 生产消息，返回消息 ID；同步模式下返回消费者的处理结果
+func (e *EventBus) Publish(ctx context.Context, b *MessageConfig, msg []byte, opts ...PublishOption) (id string, err error) {
+	o := NewPublishOptions(opts...)
+	done, err := assignSequence(ctx, e.opts.sequences, b, o)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		done(err)
+	}()
+	m := &busMessage{
+		b:       b,
+		id:      uuid.NewString(),
+		body:    msg,
+		headers: o.Headers,
+		timeout: o.Timeout,
+	}
+	switch {
+	case o.MaxRetry != nil:
+		m.maxRetry = *o.MaxRetry
+	case b.Retry != nil:
+		m.maxRetry = b.Retry.MaxRetry
+	}
+	delay := o.Delay
+	if !o.ProcessAt.IsZero() {
+		delay = time.Until(o.ProcessAt)
+	}
+	if delay > 0 {
+		return m.id, e.schedule(m, delay)
+	}
+	if e.opts.sync {
+		return m.id, e.dispatch(ctx, m)
+	}
+	return m.id, e.send(ctx, m)
+}
+
+// send 放入异步队列，队列满时阻塞
+func (e *EventBus) send(ctx context.Context, m *busMessage) error {
+	e.sendLock.RLock()
+	defer e.sendLock.RUnlock()
+	select {
+	case <-e.done:
+		return EventBusStopped
+	default:
+	}
+	select {
+	case e.queue <- m:
+		return nil
+	case <-e.done:
+		return EventBusStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// schedule 延时投递
+func (e *EventBus) schedule(m *busMessage, delay time.Duration) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.stopped {
+		return EventBusStopped
+	}
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		e.lock.Lock()
+		delete(e.timers, t)
+		e.lock.Unlock()
+		if e.opts.sync {
+			_ = e.dispatch(context.Background(), m)
+			return
+		}
+		if err := e.send(context.Background(), m); err != nil {
+			e.log.Warn("EventBus 延时消息投递失败,key:", m.b.Key, "err:", err)
+		}
+	})
+	e.timers[t] = struct{}{}
+	return nil
+}
+
+// dispatch 调用消费者，失败时按策略重试
+func (e *EventBus) dispatch(ctx context.Context, m *busMessage) error {
+	e.lock.RLock()
+	h, ok := e.handlers[m.b.Key]
+	e.lock.RUnlock()
+	if !ok {
+		e.log.Warn("EventBus 消息没有消费者,key:", m.b.Key)
+		return nil
+	}
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	ctx = NewMessageContext(ctx, &MessageInfo{
+		ID:       m.id,
+		Key:      m.b.Key,
+		Queue:    m.b.Key,
+		Retried:  m.retried,
+		MaxRetry: m.maxRetry,
+		Headers:  m.headers,
+	})
+	err := e.call(ctx, h, m.body)
+	if err == nil || e.opts.sync {
+		if err != nil {
+			e.log.Error("EventBus 消息业务处理失败,key:", m.b.Key, "err:", err)
+		}
+		return err
+	}
+	if delay, ok := RequeueDelay(err); ok {
+		return e.schedule(m, delay)
+	}
+	e.log.Error("EventBus 消息业务处理失败,key:", m.b.Key, "retried:", m.retried, "err:", err)
+	if IsNonRetryable(err) || m.retried >= m.maxRetry {
+		return err
+	}
+	policy := m.b.Retry
+	if policy == nil {
+		policy = &RetryPolicy{}
+	}
+	delay := policy.NextDelay(m.retried)
+	m.retried++
+	return e.schedule(m, delay)
+}
+
+// call 调用消费者并隔离 panic
+func (e *EventBus) call(ctx context.Context, h Handle, msg []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("eventbus handle panic: %v", r)
+		}
+	}()
+	return h(ctx, msg)
+}
+
+// register 注册消费者
+func (e *EventBus) register(b *MessageConfig, handle Handle) error {
+	if e.started {
+		return errors.Wrap(ServerAlreadyStarted, b.Key)
+	}
+	if _, ok := e.handlers[b.Key]; ok {
+		return errors.Wrap(ConsumerAlreadyRegistered, b.Key)
+	}
+	h := Chain(e.opts.middlewares...)(handle)
+	if b.Limit != nil {
+		h = Limit(*b.Limit)(h)
+	}
+	e.handlers[b.Key] = h
+	return nil
+}
+
+// ConsumerNormalRegister 注册一个普通消费者
+func (e *EventBus) ConsumerNormalRegister(b *MessageConfig, handle Handle) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.register(b, handle)
+}
+
+// ConsumerBatchRegister 注册一个批量消费者，消息在进程内聚合
+// 等待聚合的消息占用工作协程，异步模式下 Size 不能超过工作协程数，否则每个批次都要等满 Wait
+func (e *EventBus) ConsumerBatchRegister(b *MessageConfig, h BatchHandle, opts ...BatchOption) error {
+	o := newBatchOptions(b, opts...)
+	if !e.opts.sync && o.Size > e.opts.workers {
+		return errors.Wrapf(BatchSizeTooLarge, "key %s, size %d, workers %d", b.Key, o.Size, e.opts.workers)
+	}
+	return e.ConsumerNormalRegister(b, newBatcher(h, o).add)
+}
+
+// ConsumerCronRegister 注册一个定时任务，消息体为空
+func (e *EventBus) ConsumerCronRegister(b *MessageConfig, handle Handle, spec string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	schedule, err := parseCronSpec(spec, nil)
+	if err != nil {
+		return errors.Wrap(CronSpecInvalid, err.Error())
+	}
+	if err := e.register(b, handle); err != nil {
+		return err
+	}
+	e.cron.Schedule(schedule, cron.FuncJob(func() {
+		if _, err := e.Publish(context.Background(), b, nil); err != nil {
+			e.log.Error("EventBus 定时消息投递失败,key:", b.Key, "err:", err)
+		}
+	}))
+	return nil
+}
+
+// Start 启动工作协程与定时任务，不阻塞
+func (e *EventBus) Start(ctx context.Context) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.started {
+		return ServerAlreadyStarted
+	}
+	if !e.opts.sync {
+		for i := 0; i < max(e.opts.workers, 1); i++ {
+			e.wg.Add(1)
+			go e.work()
+		}
+	}
+	e.cron.Start()
+	e.started = true
+	return nil
+}
+
+func (e *EventBus) work() {
+	defer e.wg.Done()
+	for m := range e.queue {
+		_ = e.dispatch(context.Background(), m)
+	}
+}
+
+// Stop 停止接收消息，处理完队列中的消息后返回；未到期的延时消息被丢弃
+func (e *EventBus) Stop(ctx context.Context) error {
+	e.lock.Lock()
+	if e.stopped {
+		e.lock.Unlock()
+		return nil
+	}
+	close(e.done)
+	e.stopped = true
+	for t := range e.timers {
+		t.Stop()
+	}
+	if n := len(e.timers); n > 0 {
+		e.log.Warn("EventBus 停止时丢弃未到期的延时消息,count:", n)
+	}
+	e.timers = nil
+	started := e.started
+	e.lock.Unlock()
+
+	cronCtx := e.cron.Stop()
+	stopped := make(chan struct{})
+	go func() {
+		<-cronCtx.Done()
+		// 等待阻塞中的生产方退出后再关闭队列
+		e.sendLock.Lock()
+		close(e.queue)
+		e.sendLock.Unlock()
+		if started {
+			e.wg.Wait()
+		}
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,146 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+func newTestEventBus(t *testing.T, opts ...EventBusOption) *EventBus {
+	t.Helper()
+	bus := NewEventBus(log.DefaultLogger, opts...)
+	t.Cleanup(func() {
+		_ = bus.Stop(context.Background())
+	})
+	return bus
+}
+
+func TestEventBusAsync(t *testing.T) {
+	bus := newTestEventBus(t, WithEventBusWorkers(2))
+	cfg := &MessageConfig{Key: "user_registered", Retry: &RetryPolicy{MaxRetry: 2, Backoff: BackoffFixed, Delay: time.Millisecond}}
+	got := make(chan string, 10)
+	var attempts atomic.Int32
+	err := bus.ConsumerNormalRegister(cfg, func(ctx context.Context, msg []byte) error {
+		info, _ := MessageFromContext(ctx)
+		if string(msg) == "flaky" && attempts.Add(1) <= 2 {
+			return errors.New("temporary")
+		}
+		if string(msg) == "panic" {
+			panic("boom")
+		}
+		got <- string(msg) + ":" + info.Key
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.ConsumerNormalRegister(cfg, nil); !errors.Is(err, ConsumerAlreadyRegistered) {
+		t.Fatalf("expected ConsumerAlreadyRegistered, got %v", err)
+	}
+	if err := bus.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.ProducerNormalMessage(cfg, []byte("panic")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.ProducerNormalMessage(cfg, []byte("flaky")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.ProducerDelayMessage(cfg, []byte("delayed"), 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"flaky:user_registered": true, "delayed:user_registered": true}
+	for range want {
+		select {
+		case m := <-got:
+			if !want[m] {
+				t.Fatalf("unexpected message %s", m)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestEventBusSync(t *testing.T) {
+	bus := newTestEventBus(t, WithEventBusSync())
+	cfg := &MessageConfig{Key: "order_paid"}
+	_ = bus.ConsumerNormalRegister(cfg, func(ctx context.Context, msg []byte) error {
+		if string(msg) == "bad" {
+			return errors.New("bad")
+		}
+		return nil
+	})
+	_ = bus.Start(context.Background())
+	if _, err := bus.Publish(context.Background(), cfg, []byte("ok")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bus.Publish(context.Background(), cfg, []byte("bad")); err == nil {
+		t.Fatal("sync mode should return handler error")
+	}
+}
+
+func TestEventBusStop(t *testing.T) {
+	bus := NewEventBus(log.DefaultLogger, WithEventBusWorkers(1))
+	cfg := &MessageConfig{Key: "order_paid"}
+	var handled atomic.Int32
+	_ = bus.ConsumerNormalRegister(cfg, func(ctx context.Context, msg []byte) error {
+		time.Sleep(time.Millisecond)
+		handled.Add(1)
+		return nil
+	})
+	_ = bus.Start(context.Background())
+	for i := 0; i < 5; i++ {
+		_ = bus.ProducerNormalMessage(cfg, nil)
+	}
+	if err := bus.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if handled.Load() != 5 {
+		t.Fatalf("queued messages should be drained on stop, handled %d", handled.Load())
+	}
+	if err := bus.ProducerNormalMessage(cfg, nil); !errors.Is(err, EventBusStopped) {
+		t.Fatalf("expected EventBusStopped, got %v", err)
+	}
+}
+
+func TestEventBusOrdered(t *testing.T) {
+	bus := newTestEventBus(t, WithEventBusWorkers(4))
+	cfg := &MessageConfig{Key: "order_events"}
+	got := make(chan string, 2)
+	err := bus.ConsumerNormalRegister(cfg, Ordered(bus.SequenceStore(), WithOrderedRequeueDelay(5*time.Millisecond))(func(ctx context.Context, msg []byte) error {
+		got <- string(msg)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := bus.Publish(ctx, cfg, []byte("1"), WithOrderingKey("o1"), WithDelay(30*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bus.Publish(ctx, cfg, []byte("2"), WithOrderingKey("o1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1", "2"} {
+		select {
+		case m := <-got:
+			if m != want {
+				t.Fatalf("got %s, want %s", m, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for ordered messages")
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package mq
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyState 幂等记录状态
+type IdempotencyState int
+
+const (
+	IdempotencyAcquired   IdempotencyState = iota // 获取租约成功，可以处理
+	IdempotencyInProgress                         // 其他消费者正在处理
+	IdempotencyCompleted                          // 已处理完成
+)
+
+// IdempotencyStore 幂等记录存储
+type IdempotencyStore interface {
+	// Acquire 尝试获取处理租约，租约过期后可被重新获取（用于回收崩溃的处理中消息）
+	// 返回的 token 用于释放租约
+	Acquire(ctx context.Context, key string, lease time.Duration) (token string, state IdempotencyState, err error)
+	// Complete 标记处理完成，记录保留 ttl
+	Complete(ctx context.Context, key string, ttl time.Duration) error
+	// Release 释放租约，允许消息重新处理
+	Release(ctx context.Context, key, token string) error
+}
+
+// IdempotencyKeyFunc 从消息中提取幂等键
+type IdempotencyKeyFunc func(ctx context.Context, msg []byte) (string, error)
+
+type idempotencyOptions struct {
+	keyFunc         IdempotencyKeyFunc
+	lease           time.Duration
+	ttl             time.Duration
+	inProgressDelay time.Duration
+}
+
+// IdempotencyOption 幂等中间件选项
+type IdempotencyOption func(*idempotencyOptions)
+
+// WithIdempotencyKey 自定义幂等键，默认使用 MessageConfig.Key 与消息 ID
+func WithIdempotencyKey(f IdempotencyKeyFunc) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.keyFunc = f
+	}
+}
+
+// WithIdempotencyLease 设置处理租约时长，应大于消息处理的最长耗时，默认 5 分钟
+func WithIdempotencyLease(d time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.lease = d
+	}
+}
+
+// WithIdempotencyTTL 设置完成记录的保留时长，默认 24 小时
+func WithIdempotencyTTL(d time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.ttl = d
+	}
+}
+
+// WithInProgressDelay 设置消息正在被处理时重新投递的等待时间，默认 5 秒
+func WithInProgressDelay(d time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.inProgressDelay = d
+	}
+}
+
+// Idempotent 幂等消费中间件
+// 已完成的重复消息直接跳过；正在处理的重复消息稍后重新投递；处理失败时释放租约以便重试
+// 无法获取幂等键时（既没有消息 ID 也未设置 WithIdempotencyKey）不做去重
+func Idempotent(store IdempotencyStore, opts ...IdempotencyOption) Middleware {
+	o := &idempotencyOptions{
+		lease:           5 * time.Minute,
+		ttl:             24 * time.Hour,
+		inProgressDelay: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(next Handle) Handle {
+		return func(ctx context.Context, msg []byte) error {
+			key, err := idempotencyKey(ctx, msg, o.keyFunc)
+			if err != nil {
+				return err
+			}
+			if key == "" {
+				return next(ctx, msg)
+			}
+			token, state, err := store.Acquire(ctx, key, o.lease)
+			if err != nil {
+				return err
+			}
+			switch state {
+			case IdempotencyCompleted:
+				return nil
+			case IdempotencyInProgress:
+				return Requeue(MessageInProgress, o.inProgressDelay)
+			}
+			if err := next(ctx, msg); err != nil {
+				// 释放失败时等待租约过期后回收
+				_ = store.Release(context.WithoutCancel(ctx), key, token)
+				return err
+			}
+			// 消息已处理成功，记录失败不再重试，避免重复处理
+			_ = store.Complete(context.WithoutCancel(ctx), key, o.ttl)
+			return nil
+		}
+	}
+}
+
+// idempotencyKey 生成幂等键
+func idempotencyKey(ctx context.Context, msg []byte, f IdempotencyKeyFunc) (string, error) {
+	info, _ := MessageFromContext(ctx)
+	prefix := ""
+	if info != nil {
+		prefix = info.Key + ":"
+	}
+	if f != nil {
+		key, err := f(ctx, msg)
+		if err != nil || key == "" {
+			return "", err
+		}
+		return prefix + key, nil
+	}
+	if info == nil || info.ID == "" {
+		return "", nil
+	}
+	return prefix + info.ID, nil
+}
@@ -0,0 +1,79 @@
+package mq
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryIdempotencyEntry 内存幂等记录
+type memoryIdempotencyEntry struct {
+	token    string // 处理中的租约 token，为空表示已完成
+	expireAt time.Time
+}
+
+// MemoryIdempotencyStore 内存幂等存储，仅适用于单实例或测试
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryIdempotencyEntry
+	seq       uint64
+	lastSweep time.Time
+}
+
+var _ IdempotencyStore = (*MemoryIdempotencyStore)(nil)
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]*memoryIdempotencyEntry),
+	}
+}
+
+// Acquire 尝试获取处理租约
+func (m *MemoryIdempotencyStore) Acquire(ctx context.Context, key string, lease time.Duration) (string, IdempotencyState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweep(now)
+	if e, ok := m.entries[key]; ok && now.Before(e.expireAt) {
+		if e.token == "" {
+			return "", IdempotencyCompleted, nil
+		}
+		return "", IdempotencyInProgress, nil
+	}
+	m.seq++
+	token := strconv.FormatUint(m.seq, 10)
+	m.entries[key] = &memoryIdempotencyEntry{token: token, expireAt: now.Add(lease)}
+	return token, IdempotencyAcquired, nil
+}
+
+// Complete 标记处理完成
+func (m *MemoryIdempotencyStore) Complete(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = &memoryIdempotencyEntry{expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release 释放租约，仅当租约仍属于 token 时生效
+func (m *MemoryIdempotencyStore) Release(ctx context.Context, key, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok && e.token != "" && e.token == token {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// sweep 定期清理过期记录
+func (m *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for k, e := range m.entries {
+		if !now.Before(e.expireAt) {
+			delete(m.entries, k)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	idempotencyProcessing = "p:" // 处理中，后接租约 token
+	idempotencyDone       = "d"  // 已完成
+)
+
+// releaseScript 仅当租约仍属于 token 时删除
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisIdempotencyStore Redis 幂等存储，多实例共享
+type RedisIdempotencyStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ IdempotencyStore = (*RedisIdempotencyStore)(nil)
+
+// NewRedisIdempotencyStore 创建 Redis 幂等存储，prefix 为空时使用 "mq:idempotency:"
+// asynq 用户可通过 asynq.RedisClientOpt.MakeRedisClient().(redis.UniversalClient) 复用连接配置
+func NewRedisIdempotencyStore(client redis.UniversalClient, prefix string) *RedisIdempotencyStore {
+	if prefix == "" {
+		prefix = "mq:idempotency:"
+	}
+	return &RedisIdempotencyStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Acquire 尝试获取处理租约
+func (r *RedisIdempotencyStore) Acquire(ctx context.Context, key string, lease time.Duration) (string, IdempotencyState, error) {
+	token := uuid.NewString()
+	ok, err := r.client.SetNX(ctx, r.prefix+key, idempotencyProcessing+token, lease).Result()
+	if err != nil {
+		return "", IdempotencyAcquired, err
+	}
+	if ok {
+		return token, IdempotencyAcquired, nil
+	}
+	val, err := r.client.Get(ctx, r.prefix+key).Result()
+	if err != nil && err != redis.Nil {
+		return "", IdempotencyAcquired, err
+	}
+	if val == idempotencyDone {
+		return "", IdempotencyCompleted, nil
+	}
+	// 处理中，或记录恰好过期，交由下次投递重新获取
+	return "", IdempotencyInProgress, nil
+}
+
+// Complete 标记处理完成
+func (r *RedisIdempotencyStore) Complete(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, idempotencyDone, ttl).Err()
+}
+
+// Release 释放租约，仅当租约仍属于 token 时生效
+func (r *RedisIdempotencyStore) Release(ctx context.Context, key, token string) error {
+	return releaseScript.Run(ctx, r.client, []string{r.prefix + key}, idempotencyProcessing+token).Err()
+}
@@ -0,0 +1,109 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdempotent(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	calls := 0
+	var fail error
+	handle := Idempotent(store)(func(ctx context.Context, msg []byte) error {
+		calls++
+		return fail
+	})
+	ctx := NewMessageContext(context.Background(), &MessageInfo{ID: "m1", Key: "order"})
+
+	t.Run("failure_releases_lease", func(t *testing.T) {
+		fail = errors.New("db down")
+		if err := handle(ctx, nil); !errors.Is(err, fail) {
+			t.Fatalf("err = %v, want %v", err, fail)
+		}
+		fail = nil
+		if err := handle(ctx, nil); err != nil {
+			t.Fatalf("retry should succeed, got %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("calls = %d, want 2", calls)
+		}
+	})
+
+	t.Run("completed_is_skipped", func(t *testing.T) {
+		if err := handle(ctx, nil); err != nil {
+			t.Fatalf("duplicate should be skipped, got %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("calls = %d, want 2", calls)
+		}
+	})
+
+	t.Run("no_key_passes_through", func(t *testing.T) {
+		if err := handle(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+		if calls != 3 {
+			t.Errorf("calls = %d, want 3", calls)
+		}
+	})
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	if _, state, _ := store.Acquire(context.Background(), "order:m1", time.Hour); state != IdempotencyAcquired {
+		t.Fatalf("state = %v, want acquired", state)
+	}
+	handle := Idempotent(store, WithInProgressDelay(time.Second))(func(ctx context.Context, msg []byte) error {
+		t.Error("in-progress duplicate should not be handled")
+		return nil
+	})
+	ctx := NewMessageContext(context.Background(), &MessageInfo{ID: "m1", Key: "order"})
+	err := handle(ctx, nil)
+	if d, ok := RequeueDelay(err); !ok || d != time.Second || !errors.Is(err, MessageInProgress) {
+		t.Errorf("err = %v, want requeued MessageInProgress", err)
+	}
+}
+
+func TestMemoryIdempotencyStoreLease(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	ctx := context.Background()
+
+	token, _, _ := store.Acquire(ctx, "k", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	// 租约过期后可被回收
+	token2, state, _ := store.Acquire(ctx, "k", time.Hour)
+	if state != IdempotencyAcquired {
+		t.Fatalf("expired lease should be reclaimable, state = %v", state)
+	}
+	// 旧租约释放不影响新租约
+	_ = store.Release(ctx, "k", token)
+	if _, state, _ := store.Acquire(ctx, "k", time.Hour); state != IdempotencyInProgress {
+		t.Errorf("stale release should be ignored, state = %v", state)
+	}
+	_ = store.Release(ctx, "k", token2)
+	if _, state, _ := store.Acquire(ctx, "k", time.Hour); state != IdempotencyAcquired {
+		t.Errorf("state after release = %v, want acquired", state)
+	}
+}
+
+func TestIdempotentKeyFunc(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	calls := 0
+	handle := Idempotent(store, WithIdempotencyKey(func(ctx context.Context, msg []byte) (string, error) {
+		return string(msg), nil
+	}))(func(ctx context.Context, msg []byte) error {
+		calls++
+		return nil
+	})
+	for _, msg := range []string{"a", "b", "a"} {
+		if err := handle(context.Background(), []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
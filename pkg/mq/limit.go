@@ -0,0 +1,87 @@
+package mq
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-kratos/aegis/circuitbreaker"
+	"golang.org/x/time/rate"
+)
+
+// LimitPolicy 单个消费者的限制策略
+// 超出限制的消息稍后重新投递（不计入重试次数，重试次数用尽时也不会进入死信），不会占用消费者的工作协程，避免一个消费者拖慢其他消费者
+type LimitPolicy struct {
+	MaxInFlight int     `json:"max_in_flight" yaml:"max_in_flight"` // 最大并发处理数，0 表示不限制
+	Rate        float64 `json:"rate" yaml:"rate"`                   // 每秒处理消息数，0 表示不限制
+	Burst       int     `json:"burst" yaml:"burst"`                 // 令牌桶容量，默认取 Rate 向上取整
+}
+
+type limitOptions struct {
+	breaker      circuitbreaker.CircuitBreaker
+	requeueDelay time.Duration
+}
+
+// LimitOption 限制中间件选项
+type LimitOption func(*limitOptions)
+
+// WithLimitBreaker 下游依赖的熔断器打开时暂停消费
+// 熔断器的状态由调用下游的代码维护，中间件只检查是否放行
+func WithLimitBreaker(cb circuitbreaker.CircuitBreaker) LimitOption {
+	return func(o *limitOptions) {
+		o.breaker = cb
+	}
+}
+
+// WithLimitRequeueDelay 设置并发已满或熔断时重新投递的等待时间，默认 1 秒
+func WithLimitRequeueDelay(d time.Duration) LimitOption {
+	return func(o *limitOptions) {
+		o.requeueDelay = d
+	}
+}
+
+// Limit 消费者限制中间件，限制并发处理数与处理速率，并在熔断时暂停消费
+// 每次调用返回的中间件持有独立的计数，应为每个消费者单独创建
+func Limit(p LimitPolicy, opts ...LimitOption) Middleware {
+	o := &limitOptions{requeueDelay: time.Second}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var sem chan struct{}
+	if p.MaxInFlight > 0 {
+		sem = make(chan struct{}, p.MaxInFlight)
+	}
+	var limiter *rate.Limiter
+	if p.Rate > 0 {
+		burst := p.Burst
+		if burst <= 0 {
+			burst = max(int(math.Ceil(p.Rate)), 1)
+		}
+		limiter = rate.NewLimiter(rate.Limit(p.Rate), burst)
+	}
+	return func(next Handle) Handle {
+		return func(ctx context.Context, msg []byte) error {
+			if o.breaker != nil {
+				if err := o.breaker.Allow(); err != nil {
+					return Requeue(ConsumerThrottled, o.requeueDelay)
+				}
+			}
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				default:
+					return Requeue(ConsumerThrottled, o.requeueDelay)
+				}
+			}
+			if limiter != nil {
+				r := limiter.Reserve()
+				if d := r.Delay(); d > 0 {
+					r.Cancel()
+					return Requeue(ConsumerThrottled, d)
+				}
+			}
+			return next(ctx, msg)
+		}
+	}
+}
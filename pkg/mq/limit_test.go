@@ -0,0 +1,120 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/aegis/circuitbreaker"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/hibiken/asynq"
+)
+
+func TestLimitMaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := Limit(LimitPolicy{MaxInFlight: 1})(func(ctx context.Context, msg []byte) error {
+		close(started)
+		<-release
+		return nil
+	})
+	done := make(chan error)
+	go func() { done <- h(context.Background(), nil) }()
+	<-started
+
+	err := h(context.Background(), nil)
+	if _, ok := RequeueDelay(err); !ok || !errors.Is(err, ConsumerThrottled) {
+		t.Fatalf("expected throttled requeue, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimitRate(t *testing.T) {
+	calls := 0
+	h := Limit(LimitPolicy{Rate: 1, Burst: 1})(func(ctx context.Context, msg []byte) error {
+		calls++
+		return nil
+	})
+	if err := h(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	err := h(context.Background(), nil)
+	d, ok := RequeueDelay(err)
+	if !ok || d <= 0 || d > time.Second {
+		t.Fatalf("expected requeue within 1s, got %v %v", d, err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d", calls)
+	}
+}
+
+type openBreaker struct{}
+
+func (openBreaker) Allow() error { return circuitbreaker.ErrNotAllowed }
+func (openBreaker) MarkSuccess() {}
+func (openBreaker) MarkFailed()  {}
+
+func TestLimitBreaker(t *testing.T) {
+	h := Limit(LimitPolicy{}, WithLimitBreaker(openBreaker{}), WithLimitRequeueDelay(5*time.Second))(func(ctx context.Context, msg []byte) error {
+		t.Fatal("handler should not be called while breaker is open")
+		return nil
+	})
+	if d, ok := RequeueDelay(h(context.Background(), nil)); !ok || d != 5*time.Second {
+		t.Fatalf("expected 5s requeue, got %v", d)
+	}
+}
+
+// 限流时重新投递的消息在没有重试次数时也不能进入死信
+func TestLimitWithoutRetries(t *testing.T) {
+	b := &MessageConfig{
+		Key:      "limited",
+		Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:limited"},
+		Retry:    &RetryPolicy{MaxRetry: 0},
+		Limit:    &LimitPolicy{MaxInFlight: 1},
+	}
+	release := make(chan struct{})
+	done := make(chan string, 2)
+	opt := startTestAsynq(t, func(srv *AsynqServer) {
+		_ = srv.ConsumerNormalRegister(b, func(ctx context.Context, msg []byte) error {
+			<-release
+			done <- string(msg)
+			return nil
+		})
+	})
+	client := NewAsynqClient(log.DefaultLogger, opt)
+	for _, msg := range []string{"a", "b"} {
+		if _, err := client.Publish(context.Background(), b, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insp := asynq.NewInspector(opt)
+	defer insp.Close()
+	// 等待第二条消息因并发已满被重新投递
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		q, err := insp.GetQueueInfo("default")
+		if err == nil && q.Scheduled+q.Retry > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("throttled message was not requeued, queue = %+v err = %v", q, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("throttled message was not processed")
+		}
+	}
+	if q, err := insp.GetQueueInfo("default"); err != nil || q.Archived != 0 {
+		t.Fatalf("throttled message should not be archived, queue = %+v err = %v", q, err)
+	}
+}
@@ -0,0 +1,139 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	metricResultSuccess  = "success"
+	metricResultError    = "error"
+	metricResultRequeued = "requeued" // 稍后重新投递，不计入失败
+)
+
+// Metrics 消息队列指标
+type Metrics struct {
+	publishTotal   metric.Int64Counter     // 生产消息数，按 key、result 区分
+	consumeTotal   metric.Int64Counter     // 消费消息数，按 key、result 区分
+	consumeSeconds metric.Float64Histogram // 消费耗时
+	retryTotal     metric.Int64Counter     // 重试消费次数
+}
+
+type metricsOptions struct {
+	meter metric.Meter
+}
+
+// MetricsOption 指标选项
+type MetricsOption func(*metricsOptions)
+
+// WithMetricsMeter 设置注册指标的 Meter，默认使用 otel 全局 MeterProvider 的 "mq"
+func WithMetricsMeter(meter metric.Meter) MetricsOption {
+	return func(o *metricsOptions) {
+		o.meter = meter
+	}
+}
+
+// NewMetrics 创建指标
+func NewMetrics(opts ...MetricsOption) (*Metrics, error) {
+	o := &metricsOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	meter := o.meter
+	if meter == nil {
+		meter = otel.GetMeterProvider().Meter("mq")
+	}
+	m := &Metrics{}
+	var err error
+	if m.publishTotal, err = meter.Int64Counter(
+		"mq_publish_total",
+		metric.WithDescription("The total number of published messages"),
+	); err != nil {
+		return nil, err
+	}
+	if m.consumeTotal, err = meter.Int64Counter(
+		"mq_consume_total",
+		metric.WithDescription("The total number of consumed messages"),
+	); err != nil {
+		return nil, err
+	}
+	if m.consumeSeconds, err = meter.Float64Histogram(
+		"mq_consume_duration_seconds",
+		metric.WithDescription("The duration of message consumption"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60),
+	); err != nil {
+		return nil, err
+	}
+	if m.retryTotal, err = meter.Int64Counter(
+		"mq_retry_total",
+		metric.WithDescription("The total number of retried message consumptions"),
+	); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var (
+	defaultMetricsOnce sync.Once
+	defaultMetrics     *Metrics
+)
+
+// DefaultMetrics 基于 otel 全局 MeterProvider 的指标，由 otel.SetMeterProvider 设置的导出器暴露
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		m, err := NewMetrics()
+		if err != nil {
+			panic(err)
+		}
+		defaultMetrics = m
+	})
+	return defaultMetrics
+}
+
+func metricResult(err error) string {
+	if err == nil {
+		return metricResultSuccess
+	}
+	if _, ok := RequeueDelay(err); ok {
+		return metricResultRequeued
+	}
+	return metricResultError
+}
+
+// recordPublish 记录生产结果
+func (m *Metrics) recordPublish(ctx context.Context, key string, err error) {
+	m.publishTotal.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("key", key),
+		attribute.String("result", metricResult(err)),
+	))
+}
+
+// Observe 消费指标中间件，key 取自 MessageInfo
+func (m *Metrics) Observe() Middleware {
+	return func(next Handle) Handle {
+		return func(ctx context.Context, msg []byte) error {
+			key := ""
+			if info, ok := MessageFromContext(ctx); ok {
+				key = info.Key
+				if info.Retried > 0 {
+					m.retryTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("key", key)))
+				}
+			}
+			start := time.Now()
+			err := next(ctx, msg)
+			attrs := metric.WithAttributes(attribute.String("key", key))
+			m.consumeSeconds.Record(ctx, time.Since(start).Seconds(), attrs)
+			m.consumeTotal.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("key", key),
+				attribute.String("result", metricResult(err)),
+			))
+			return err
+		}
+	}
+}
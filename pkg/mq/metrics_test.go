@@ -0,0 +1,90 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestEnvelope(t *testing.T) {
+	body := []byte(`{"order_id":"o1"}`)
+	payload, err := encodeEnvelope(nil, body)
+	if err != nil || string(payload) != string(body) {
+		t.Fatalf("payload without headers should be unchanged, got %q", payload)
+	}
+	headers, got := decodeEnvelope(payload)
+	if headers != nil || string(got) != string(body) {
+		t.Fatalf("decode raw payload: headers=%v body=%q", headers, got)
+	}
+
+	payload, err = encodeEnvelope(map[string]string{"traceparent": "00-abc"}, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, got = decodeEnvelope(payload)
+	if headers["traceparent"] != "00-abc" || string(got) != string(body) {
+		t.Fatalf("decode envelope: headers=%v body=%q", headers, got)
+	}
+
+	// 截断的信封按原始消息体处理
+	headers, got = decodeEnvelope(payload[:len(envelopeMagic)+2])
+	if headers != nil || len(got) != len(envelopeMagic)+2 {
+		t.Fatalf("truncated envelope: headers=%v body=%q", headers, got)
+	}
+}
+
+func TestMetricsObserve(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	m, err := NewMetrics(WithMetricsMeter(provider.Meter("mq")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := m.Observe()(func(ctx context.Context, msg []byte) error {
+		switch string(msg) {
+		case "fail":
+			return errors.New("fail")
+		case "throttled":
+			return Requeue(ConsumerThrottled, time.Second)
+		}
+		return nil
+	})
+	ctx := NewMessageContext(context.Background(), &MessageInfo{Key: "order_created", Retried: 1})
+	_ = h(ctx, []byte("ok"))
+	_ = h(ctx, []byte("fail"))
+	_ = h(ctx, []byte("throttled"))
+	m.recordPublish(context.Background(), "order_created", nil)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	sums := map[string]map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			data, ok := md.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			sums[md.Name] = map[string]int64{}
+			for _, dp := range data.DataPoints {
+				result, _ := dp.Attributes.Value(attribute.Key("result"))
+				sums[md.Name][result.AsString()] += dp.Value
+			}
+		}
+	}
+	if sums["mq_consume_total"]["success"] != 1 || sums["mq_consume_total"]["error"] != 1 || sums["mq_consume_total"]["requeued"] != 1 {
+		t.Fatalf("unexpected consume total: %v", sums["mq_consume_total"])
+	}
+	if sums["mq_retry_total"][""] != 3 {
+		t.Fatalf("unexpected retry total: %v", sums["mq_retry_total"])
+	}
+	if sums["mq_publish_total"]["success"] != 1 {
+		t.Fatalf("unexpected publish total: %v", sums["mq_publish_total"])
+	}
+}
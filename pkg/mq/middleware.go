@@ -0,0 +1,14 @@
+package mq
+
+// Middleware 消费者中间件
+type Middleware func(Handle) Handle
+
+// Chain 按顺序组合多个中间件，第一个中间件位于最外层
+func Chain(m ...Middleware) Middleware {
+	return func(next Handle) Handle {
+		for i := len(m) - 1; i >= 0; i-- {
+			next = m[i](next)
+		}
+		return next
+	}
+}
@@ -15,11 +15,17 @@ type Client interface {
 	ProducerDelayMessage(b *MessageConfig, msg []byte, t time.Duration) error
 }
 
+// Producer 支持 context 与选项的生产者
+type Producer interface {
+	// Publish 生产消息，返回消息 ID
+	Publish(ctx context.Context, b *MessageConfig, msg []byte, opts ...PublishOption) (string, error)
+}
+
 type Server interface {
-	// ConsumerNormalRegister 注册一个普通消费者
-	ConsumerNormalRegister(b *MessageConfig, handle Handle)
-	// ConsumerCronRegister 注册一个定时任务
-	ConsumerCronRegister(b *MessageConfig, handle Handle, cron string)
+	// ConsumerNormalRegister 注册一个普通消费者，仅允许在启动前调用
+	ConsumerNormalRegister(b *MessageConfig, handle Handle) error
+	// ConsumerCronRegister 注册一个定时任务，仅允许在启动前调用
+	ConsumerCronRegister(b *MessageConfig, handle Handle, cron string) error
 	// Start 启动
 	Start(context.Context) error
 	// Stop 停止
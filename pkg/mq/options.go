@@ -0,0 +1,118 @@
+package mq
+
+import "time"
+
+// Priority 消息优先级，对应默认配置中的队列
+type Priority string
+
+const (
+	PriorityCritical Priority = "critical" // 高优先级
+	PriorityDefault  Priority = "default"  // 默认优先级
+	PriorityLow      Priority = "low"      // 低优先级
+)
+
+// PublishOptions 生产消息参数
+type PublishOptions struct {
+	ProcessAt time.Time         // 指定处理时间
+	Delay     time.Duration     // 延迟处理时间，ProcessAt 非零时忽略
+	UniqueKey string            // 去重键，相同键的消息在保留期内只会存在一条
+	UniqueTTL time.Duration     // 去重时长
+	Queue     string            // 指定队列，优先于 Priority
+	Priority  Priority          // 优先级
+	MaxRetry  *int              // 最大重试次数，优先于 MessageConfig.Retry
+	Timeout   time.Duration     // 单次处理超时时间
+	Retention time.Duration     // 处理成功后的保留时间
+	Headers   map[string]string // 消息头，消费时通过 MessageInfo.Headers 获取
+	// OrderingKey 顺序键，相同顺序键的消息按生产顺序消费
+	OrderingKey string
+}
+
+// PublishOption 生产消息选项
+type PublishOption func(*PublishOptions)
+
+// NewPublishOptions 合并生产消息选项
+func NewPublishOptions(opts ...PublishOption) *PublishOptions {
+	o := &PublishOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithProcessAt 指定消息的处理时间
+func WithProcessAt(t time.Time) PublishOption {
+	return func(o *PublishOptions) {
+		o.ProcessAt = t
+	}
+}
+
+// WithDelay 延迟处理消息
+func WithDelay(d time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.Delay = d
+	}
+}
+
+// WithUnique 按消息内容去重，ttl 内重复的消息会被拒绝
+func WithUnique(ttl time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.UniqueTTL = ttl
+	}
+}
+
+// WithUniqueKey 按指定键去重，键在消息处理完成后仍保留 ttl
+func WithUniqueKey(key string, ttl time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.UniqueKey = key
+		if ttl > o.Retention {
+			o.Retention = ttl
+		}
+	}
+}
+
+// WithQueue 指定投递队列
+func WithQueue(queue string) PublishOption {
+	return func(o *PublishOptions) {
+		o.Queue = queue
+	}
+}
+
+// WithPriority 指定消息优先级
+func WithPriority(p Priority) PublishOption {
+	return func(o *PublishOptions) {
+		o.Priority = p
+	}
+}
+
+// WithMaxRetry 指定最大重试次数
+func WithMaxRetry(n int) PublishOption {
+	return func(o *PublishOptions) {
+		o.MaxRetry = &n
+	}
+}
+
+// WithTimeout 指定单次处理超时时间
+func WithTimeout(d time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.Timeout = d
+	}
+}
+
+// WithRetention 指定处理成功后的保留时间
+func WithRetention(d time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		if d > o.Retention {
+			o.Retention = d
+		}
+	}
+}
+
+// WithHeader 设置消息头
+func WithHeader(key, value string) PublishOption {
+	return func(o *PublishOptions) {
+		if o.Headers == nil {
+			o.Headers = make(map[string]string)
+		}
+		o.Headers[key] = value
+	}
+}
@@ -0,0 +1,60 @@
+package mq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestAsynqOptions(t *testing.T) {
+	b := &MessageConfig{Key: "k", Retry: &RetryPolicy{MaxRetry: 3}}
+	at := time.Now().Add(time.Hour)
+
+	t.Run("defaults_from_config", func(t *testing.T) {
+		opts := asynqOptions(b, NewPublishOptions())
+		if len(opts) != 1 || opts[0].Type() != asynq.MaxRetryOpt || opts[0].Value() != 3 {
+			t.Errorf("opts = %v, want [MaxRetry(3)]", opts)
+		}
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		opts := asynqOptions(b, NewPublishOptions(
+			WithMaxRetry(1),
+			WithProcessAt(at),
+			WithDelay(time.Minute),
+			WithUniqueKey("order:1", time.Hour),
+			WithPriority(PriorityLow),
+			WithQueue("reports"),
+			WithTimeout(time.Second),
+		))
+		got := make(map[asynq.OptionType]any)
+		for _, o := range opts {
+			got[o.Type()] = o.Value()
+		}
+		want := map[asynq.OptionType]any{
+			asynq.MaxRetryOpt:  1,
+			asynq.ProcessAtOpt: at,
+			asynq.TaskIDOpt:    "order:1",
+			asynq.QueueOpt:     "reports",
+			asynq.TimeoutOpt:   time.Second,
+			asynq.RetentionOpt: time.Hour,
+		}
+		if len(got) != len(want) {
+			t.Fatalf("opts = %v, want %v", got, want)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("option %v = %v, want %v", k, got[k], v)
+			}
+		}
+	})
+}
+
+func TestTopicPublishOptionsNotSupported(t *testing.T) {
+	topic := NewTopic[string](&fakeClient{}, &MessageConfig{Key: "k"}, nil)
+	if err := topic.Publish(context.Background(), "x", WithQueue("low")); err != ProducerNotSupported {
+		t.Errorf("err = %v, want ProducerNotSupported", err)
+	}
+}
@@ -0,0 +1,165 @@
+package mq
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderOrderingKey = "mq-ordering-key" // 顺序键消息头
+	HeaderSequence    = "mq-sequence"     // 顺序号消息头，同一顺序键从 1 开始递增
+)
+
+// SequenceStore 顺序号存储，生产端分配顺序号，消费端记录下一个待处理的顺序号
+// scope 由 MessageConfig.Key 与顺序键组成，生产与消费记录需同时过期，避免顺序号回退后消息被误判为重复
+type SequenceStore interface {
+	// Next 为生产端分配下一个顺序号
+	Next(ctx context.Context, scope string) (int64, error)
+	// Skip 标记顺序号作废（生产失败），消费端不再等待该顺序号
+	Skip(ctx context.Context, scope string, seq int64) error
+	// Expected 返回消费端下一个待处理的顺序号，会跳过已作废的顺序号
+	Expected(ctx context.Context, scope string) (int64, error)
+	// Advance 标记 seq 已处理，下一个待处理的顺序号变为 seq+1
+	Advance(ctx context.Context, scope string, seq int64) error
+	// Wait 记录消费端开始等待 seq 的时间并返回已等待的时长，等待的顺序号变化后重新计时
+	Wait(ctx context.Context, scope string, seq int64) (time.Duration, error)
+}
+
+// WithOrderingKey 指定顺序键，相同顺序键的消息在使用 Ordered 中间件的消费者中按生产顺序处理
+func WithOrderingKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.OrderingKey = key
+	}
+}
+
+// sequenceScope 顺序号作用域
+func sequenceScope(b *MessageConfig, orderingKey string) string {
+	return b.Key + ":" + orderingKey
+}
+
+// assignSequence 为带顺序键的消息分配顺序号并写入消息头
+// 返回的 done 需在投递完成后调用，投递失败时作废顺序号
+func assignSequence(ctx context.Context, store SequenceStore, b *MessageConfig, o *PublishOptions) (done func(error), err error) {
+	if o.OrderingKey == "" {
+		return func(error) {}, nil
+	}
+	scope := sequenceScope(b, o.OrderingKey)
+	seq, err := store.Next(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	if o.Headers == nil {
+		o.Headers = make(map[string]string)
+	}
+	o.Headers[HeaderOrderingKey] = o.OrderingKey
+	o.Headers[HeaderSequence] = strconv.FormatInt(seq, 10)
+	return func(err error) {
+		if err != nil {
+			_ = store.Skip(context.WithoutCancel(ctx), scope, seq)
+		}
+	}, nil
+}
+
+type orderedOptions struct {
+	requeueDelay    time.Duration
+	maxRequeueDelay time.Duration
+	gapTimeout      time.Duration
+}
+
+// OrderedOption 顺序消费中间件选项
+type OrderedOption func(*orderedOptions)
+
+// WithOrderedRequeueDelay 设置乱序消息首次重新投递的等待时间，默认 200 毫秒
+// 之后的等待时间随前序消息缺失的时长增长，不超过 WithOrderedMaxRequeueDelay
+func WithOrderedRequeueDelay(d time.Duration) OrderedOption {
+	return func(o *orderedOptions) {
+		o.requeueDelay = d
+	}
+}
+
+// WithOrderedMaxRequeueDelay 设置乱序消息重新投递的最长等待时间，默认 10 秒
+func WithOrderedMaxRequeueDelay(d time.Duration) OrderedOption {
+	return func(o *orderedOptions) {
+		o.maxRequeueDelay = d
+	}
+}
+
+// WithOrderedGapTimeout 设置前序消息缺失的最长等待时间，默认 24 小时，0 表示一直等待
+// 前序消息被删除、归档或过期时不会再到达，超时后跳过该顺序号，避免顺序键永久阻塞；
+// 应大于消息重试的总时长，否则超时后才重试成功的前序消息会被当作重复消息跳过
+func WithOrderedGapTimeout(d time.Duration) OrderedOption {
+	return func(o *orderedOptions) {
+		o.gapTimeout = d
+	}
+}
+
+// Ordered 顺序消费中间件，保证同一顺序键的消息按生产顺序处理，不同顺序键之间仍并发处理
+// 未到顺序的消息稍后重新投递（不计入重试次数）；顺序号小于待处理顺序号的消息视为重复直接跳过；
+// 消息最终失败（重试耗尽或不可重试）时同样推进顺序，避免阻塞后续消息，失败消息可在死信队列中处理
+// 没有顺序键的消息不受影响
+func Ordered(store SequenceStore, opts ...OrderedOption) Middleware {
+	o := &orderedOptions{
+		requeueDelay:    200 * time.Millisecond,
+		maxRequeueDelay: 10 * time.Second,
+		gapTimeout:      24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(next Handle) Handle {
+		return func(ctx context.Context, msg []byte) error {
+			info, ok := MessageFromContext(ctx)
+			if !ok || info.Headers[HeaderOrderingKey] == "" {
+				return next(ctx, msg)
+			}
+			seq, err := strconv.ParseInt(info.Headers[HeaderSequence], 10, 64)
+			if err != nil {
+				return next(ctx, msg)
+			}
+			scope := info.Key + ":" + info.Headers[HeaderOrderingKey]
+			for {
+				expected, err := store.Expected(ctx, scope)
+				if err != nil {
+					return err
+				}
+				if seq < expected {
+					return nil
+				}
+				if seq == expected {
+					break
+				}
+				waited, err := store.Wait(ctx, scope, expected)
+				if err != nil {
+					return err
+				}
+				if o.gapTimeout <= 0 || waited < o.gapTimeout {
+					return Requeue(MessageOutOfOrder, o.backoff(waited))
+				}
+				// 前序消息长时间未到达，跳过缺失的顺序号
+				if err := store.Skip(ctx, scope, expected); err != nil {
+					return err
+				}
+			}
+			err = next(ctx, msg)
+			if err != nil {
+				if _, requeue := RequeueDelay(err); requeue || (!IsNonRetryable(err) && info.Retried < info.MaxRetry) {
+					return err
+				}
+			}
+			if advanceErr := store.Advance(context.WithoutCancel(ctx), scope, seq); advanceErr != nil && err == nil {
+				return advanceErr
+			}
+			return err
+		}
+	}
+}
+
+// backoff 计算乱序消息重新投递的等待时间，等待时间与前序消息已缺失的时长相当，即每次等待约翻倍
+func (o *orderedOptions) backoff(waited time.Duration) time.Duration {
+	d := max(o.requeueDelay, waited)
+	if o.maxRequeueDelay > 0 {
+		d = min(d, o.maxRequeueDelay)
+	}
+	return d
+}
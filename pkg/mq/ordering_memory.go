@@ -0,0 +1,132 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySequence 单个作用域的顺序号记录
+type memorySequence struct {
+	produced     int64
+	expected     int64
+	skipped      map[int64]struct{}
+	waiting      int64     // 消费端等待的顺序号
+	waitingSince time.Time // 开始等待的时间
+	active       time.Time // 最近一次访问的时间
+}
+
+// drained 已生产的顺序号全部处理或作废
+func (s *memorySequence) drained() bool {
+	return s.expected > s.produced && len(s.skipped) == 0
+}
+
+// MemorySequenceOption 内存顺序号存储选项
+type MemorySequenceOption func(*MemorySequenceStore)
+
+// WithMemorySequenceTTL 设置作用域记录的保留时间，默认 1 小时
+// 已没有待处理顺序号的作用域超过保留时间无生产与消费时删除，之后重复投递的旧消息不再被识别为重复
+func WithMemorySequenceTTL(d time.Duration) MemorySequenceOption {
+	return func(m *MemorySequenceStore) {
+		m.ttl = d
+	}
+}
+
+// MemorySequenceStore 内存顺序号存储，仅适用于单实例或测试
+type MemorySequenceStore struct {
+	mu     sync.Mutex
+	scopes map[string]*memorySequence
+	ttl    time.Duration
+	swept  time.Time // 最近一次清理的时间
+}
+
+var _ SequenceStore = (*MemorySequenceStore)(nil)
+
+func NewMemorySequenceStore(opts ...MemorySequenceOption) *MemorySequenceStore {
+	m := &MemorySequenceStore{
+		scopes: make(map[string]*memorySequence),
+		ttl:    time.Hour,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *MemorySequenceStore) scope(scope string) *memorySequence {
+	now := time.Now()
+	m.sweep(now)
+	s, ok := m.scopes[scope]
+	if !ok {
+		s = &memorySequence{expected: 1, skipped: make(map[int64]struct{})}
+		m.scopes[scope] = s
+	}
+	s.active = now
+	return s
+}
+
+// sweep 删除超过保留时间无访问且已没有待处理顺序号的作用域，每个保留时间最多清理一次
+func (m *MemorySequenceStore) sweep(now time.Time) {
+	if m.ttl <= 0 || now.Sub(m.swept) < m.ttl {
+		return
+	}
+	m.swept = now
+	for name, s := range m.scopes {
+		if s.drained() && now.Sub(s.active) >= m.ttl {
+			delete(m.scopes, name)
+		}
+	}
+}
+
+// Next 分配下一个顺序号
+func (m *MemorySequenceStore) Next(ctx context.Context, scope string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.scope(scope)
+	s.produced++
+	return s.produced, nil
+}
+
+// Skip 作废顺序号
+func (m *MemorySequenceStore) Skip(ctx context.Context, scope string, seq int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scope(scope).skipped[seq] = struct{}{}
+	return nil
+}
+
+// Expected 返回下一个待处理的顺序号
+func (m *MemorySequenceStore) Expected(ctx context.Context, scope string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.scope(scope)
+	for {
+		if _, ok := s.skipped[s.expected]; !ok {
+			return s.expected, nil
+		}
+		delete(s.skipped, s.expected)
+		s.expected++
+	}
+}
+
+// Advance 标记顺序号已处理
+func (m *MemorySequenceStore) Advance(ctx context.Context, scope string, seq int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.scope(scope)
+	if s.expected <= seq {
+		s.expected = seq + 1
+	}
+	return nil
+}
+
+// Wait 记录开始等待顺序号的时间并返回已等待的时长
+func (m *MemorySequenceStore) Wait(ctx context.Context, scope string, seq int64) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.scope(scope)
+	if s.waiting != seq || s.waitingSince.IsZero() {
+		s.waiting, s.waitingSince = seq, time.Now()
+	}
+	return time.Since(s.waitingSince), nil
+}
@@ -0,0 +1,110 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 顺序号记录保存在同一个 hash 中，字段 p 为已分配的顺序号，c 为下一个待处理的顺序号，s:<seq> 为作废的顺序号，
+// w 为消费端等待的顺序号与开始等待的毫秒时间戳（<seq>:<ms>）
+var (
+	sequenceNextScript = redis.NewScript(`
+local seq = redis.call("HINCRBY", KEYS[1], "p", 1)
+redis.call("PEXPIRE", KEYS[1], ARGV[1])
+return seq
+`)
+	sequenceSkipScript = redis.NewScript(`
+redis.call("HSET", KEYS[1], "s:" .. ARGV[1], 1)
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+	sequenceExpectedScript = redis.NewScript(`
+local c = tonumber(redis.call("HGET", KEYS[1], "c") or "1")
+local start = c
+while redis.call("HDEL", KEYS[1], "s:" .. c) == 1 do
+	c = c + 1
+end
+if c ~= start then
+	redis.call("HSET", KEYS[1], "c", c)
+end
+return c
+`)
+	sequenceAdvanceScript = redis.NewScript(`
+local c = tonumber(redis.call("HGET", KEYS[1], "c") or "1")
+local seq = tonumber(ARGV[1])
+if c <= seq then
+	redis.call("HSET", KEYS[1], "c", seq + 1)
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+	sequenceWaitScript = redis.NewScript(`
+local now = tonumber(ARGV[2])
+local w = redis.call("HGET", KEYS[1], "w")
+if w then
+	local seq, since = string.match(w, "^(%d+):(%d+)$")
+	if seq == ARGV[1] then
+		return now - tonumber(since)
+	end
+end
+redis.call("HSET", KEYS[1], "w", ARGV[1] .. ":" .. ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 0
+`)
+)
+
+// RedisSequenceStore Redis 顺序号存储，多实例共享
+type RedisSequenceStore struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+var _ SequenceStore = (*RedisSequenceStore)(nil)
+
+// NewRedisSequenceStore 创建 Redis 顺序号存储，prefix 为空时使用 "mq:sequence:"
+// 记录在 ttl 内无生产与消费时过期，ttl 为 0 时默认 7 天，应大于消息的最长积压时间
+func NewRedisSequenceStore(client redis.UniversalClient, prefix string, ttl time.Duration) *RedisSequenceStore {
+	if prefix == "" {
+		prefix = "mq:sequence:"
+	}
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	return &RedisSequenceStore{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// Next 分配下一个顺序号
+func (r *RedisSequenceStore) Next(ctx context.Context, scope string) (int64, error) {
+	return sequenceNextScript.Run(ctx, r.client, []string{r.prefix + scope}, r.ttl.Milliseconds()).Int64()
+}
+
+// Skip 作废顺序号
+func (r *RedisSequenceStore) Skip(ctx context.Context, scope string, seq int64) error {
+	return sequenceSkipScript.Run(ctx, r.client, []string{r.prefix + scope}, seq, r.ttl.Milliseconds()).Err()
+}
+
+// Expected 返回下一个待处理的顺序号
+func (r *RedisSequenceStore) Expected(ctx context.Context, scope string) (int64, error) {
+	return sequenceExpectedScript.Run(ctx, r.client, []string{r.prefix + scope}).Int64()
+}
+
+// Advance 标记顺序号已处理
+func (r *RedisSequenceStore) Advance(ctx context.Context, scope string, seq int64) error {
+	return sequenceAdvanceScript.Run(ctx, r.client, []string{r.prefix + scope}, seq, r.ttl.Milliseconds()).Err()
+}
+
+// Wait 记录开始等待顺序号的时间并返回已等待的时长，时间以消费端时钟为准
+func (r *RedisSequenceStore) Wait(ctx context.Context, scope string, seq int64) (time.Duration, error) {
+	ms, err := sequenceWaitScript.Run(ctx, r.client, []string{r.prefix + scope}, seq, time.Now().UnixMilli(), r.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(max(ms, 0)) * time.Millisecond, nil
+}
@@ -0,0 +1,214 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// orderedMessage 模拟生产一条带顺序键的消息，返回消费时的 context
+func orderedMessage(t *testing.T, store SequenceStore, b *MessageConfig, key string, fail bool) context.Context {
+	t.Helper()
+	o := NewPublishOptions(WithOrderingKey(key))
+	done, err := assignSequence(context.Background(), store, b, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fail {
+		done(errors.New("enqueue failed"))
+	} else {
+		done(nil)
+	}
+	return NewMessageContext(context.Background(), &MessageInfo{Key: b.Key, MaxRetry: 3, Headers: o.Headers})
+}
+
+func TestOrdered(t *testing.T) {
+	store := NewMemorySequenceStore()
+	b := &MessageConfig{Key: "order_events"}
+	first := orderedMessage(t, store, b, "o1", false)
+	second := orderedMessage(t, store, b, "o1", false)
+	other := orderedMessage(t, store, b, "o2", false)
+
+	var handled []string
+	h := Ordered(store)(func(ctx context.Context, msg []byte) error {
+		handled = append(handled, string(msg))
+		return nil
+	})
+
+	err := h(second, []byte("o1-2"))
+	if _, ok := RequeueDelay(err); !ok || !errors.Is(err, MessageOutOfOrder) {
+		t.Fatalf("expected out of order requeue, got %v", err)
+	}
+	if err := h(other, []byte("o2-1")); err != nil {
+		t.Fatalf("other keys should not be blocked: %v", err)
+	}
+	if err := h(first, []byte("o1-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h(second, []byte("o1-2")); err != nil {
+		t.Fatal(err)
+	}
+	// 重复投递直接跳过
+	if err := h(first, []byte("o1-1")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"o2-1", "o1-1", "o1-2"}
+	if len(handled) != len(want) {
+		t.Fatalf("handled = %v, want %v", handled, want)
+	}
+	for i := range want {
+		if handled[i] != want[i] {
+			t.Fatalf("handled = %v, want %v", handled, want)
+		}
+	}
+}
+
+// 没有待处理顺序号的作用域超过保留时间后删除，仍有待处理顺序号的作用域保留
+func TestMemorySequenceStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySequenceStore(WithMemorySequenceTTL(10 * time.Millisecond))
+	for _, scope := range []string{"drained", "pending"} {
+		if _, err := store.Next(ctx, scope); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = store.Advance(ctx, "drained", 1)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := store.Next(ctx, "other"); err != nil {
+		t.Fatal(err)
+	}
+	store.mu.Lock()
+	_, drained := store.scopes["drained"]
+	_, pending := store.scopes["pending"]
+	store.mu.Unlock()
+	if drained || !pending {
+		t.Fatalf("drained scope kept = %v, pending scope kept = %v", drained, pending)
+	}
+	if seq, _ := store.Next(ctx, "drained"); seq != 1 {
+		t.Fatalf("sequence of a removed scope restarts at 1, got %d", seq)
+	}
+}
+
+func TestOrderedSkipAndFailure(t *testing.T) {
+	store := NewMemorySequenceStore()
+	b := &MessageConfig{Key: "order_events"}
+	orderedMessage(t, store, b, "o1", true) // 生产失败，顺序号作废
+	second := orderedMessage(t, store, b, "o1", false)
+	third := orderedMessage(t, store, b, "o1", false)
+
+	h := Ordered(store)(func(ctx context.Context, msg []byte) error {
+		if string(msg) == "bad" {
+			return NonRetryable(errors.New("bad"))
+		}
+		return nil
+	})
+	if err := h(second, []byte("bad")); !IsNonRetryable(err) {
+		t.Fatalf("expected non retryable error, got %v", err)
+	}
+	// 最终失败的消息推进顺序，不阻塞后续消息
+	if err := h(third, []byte("ok")); err != nil {
+		t.Fatalf("expected third message to be processed, got %v", err)
+	}
+}
+
+func TestOrderedRetryBlocks(t *testing.T) {
+	store := NewMemorySequenceStore()
+	b := &MessageConfig{Key: "order_events"}
+	first := orderedMessage(t, store, b, "o1", false)
+	second := orderedMessage(t, store, b, "o1", false)
+
+	h := Ordered(store)(func(ctx context.Context, msg []byte) error {
+		if string(msg) == "retry" {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+	if err := h(first, []byte("retry")); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := RequeueDelay(h(second, []byte("ok"))); !ok {
+		t.Fatal("second message should wait for the retrying first message")
+	}
+}
+
+func TestOrderedGapTimeout(t *testing.T) {
+	stores := map[string]SequenceStore{
+		"memory": NewMemorySequenceStore(),
+		"redis":  NewRedisSequenceStore(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}), "", 0),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			b := &MessageConfig{Key: "order_events"}
+			orderedMessage(t, store, b, "o1", false) // 前序消息已被删除，不会到达
+			second := orderedMessage(t, store, b, "o1", false)
+
+			called := false
+			h := Ordered(store,
+				WithOrderedRequeueDelay(10*time.Millisecond),
+				WithOrderedMaxRequeueDelay(time.Second),
+				WithOrderedGapTimeout(100*time.Millisecond),
+			)(func(ctx context.Context, msg []byte) error {
+				called = true
+				return nil
+			})
+			if d, ok := RequeueDelay(h(second, nil)); !ok || d != 10*time.Millisecond {
+				t.Fatalf("first requeue delay = %v, want 10ms", d)
+			}
+			time.Sleep(60 * time.Millisecond)
+			// 等待时间随缺失时长增长
+			if d, ok := RequeueDelay(h(second, nil)); !ok || d < 50*time.Millisecond {
+				t.Fatalf("requeue delay = %v, want backoff", d)
+			}
+			time.Sleep(60 * time.Millisecond)
+			if err := h(second, nil); err != nil || !called {
+				t.Fatalf("stale gap should be skipped, err = %v called = %v", err, called)
+			}
+		})
+	}
+}
+
+// asynq 上乱序的消息在没有重试次数时也不能进入死信
+func TestOrderedAsynq(t *testing.T) {
+	b := &MessageConfig{Key: "order_events", Metadata: map[MetaKey]string{MetaKeyAsynqQueue: "task:order_events"}}
+	store := NewMemorySequenceStore()
+	var (
+		mu      sync.Mutex
+		handled []string
+	)
+	done := make(chan struct{}, 2)
+	opt := startTestAsynq(t, func(srv *AsynqServer) {
+		_ = srv.ConsumerNormalRegister(b, Ordered(store, WithOrderedRequeueDelay(20*time.Millisecond))(func(ctx context.Context, msg []byte) error {
+			mu.Lock()
+			handled = append(handled, string(msg))
+			mu.Unlock()
+			done <- struct{}{}
+			return nil
+		}))
+	})
+	client := NewAsynqClient(log.DefaultLogger, opt, WithClientSequenceStore(store))
+	ctx := context.Background()
+	if _, err := client.Publish(ctx, b, []byte("1"), WithOrderingKey("o1"), WithMaxRetry(0), WithDelay(200*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Publish(ctx, b, []byte("2"), WithOrderingKey("o1"), WithMaxRetry(0)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("handled = %v, want both messages", handled)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 2 || handled[0] != "1" || handled[1] != "2" {
+		t.Fatalf("handled = %v, want [1 2]", handled)
+	}
+}
@@ -0,0 +1,98 @@
+package mq
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Requester 请求-响应，生产消息后等待消费者的返回值
+type Requester interface {
+	// Request 生产消息并等待响应，timeout 为 0 时仅受 ctx 控制
+	// 消费失败并进入死信时返回 RequestFailed，超时返回 ReplyTimeout
+	Request(ctx context.Context, b *MessageConfig, msg []byte, timeout time.Duration, opts ...PublishOption) ([]byte, error)
+}
+
+// ReplyHandle 带返回值的消费者业务方法
+type ReplyHandle func(ctx context.Context, msg []byte) ([]byte, error)
+
+type replyWriterKey struct{}
+
+// NewReplyContext 将响应写入器放入 context，由消息队列实现在消费时调用
+func NewReplyContext(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, replyWriterKey{}, w)
+}
+
+// ReplyWriterFromContext 从 context 中获取响应写入器
+func ReplyWriterFromContext(ctx context.Context) (io.Writer, bool) {
+	w, ok := ctx.Value(replyWriterKey{}).(io.Writer)
+	return w, ok
+}
+
+// Reply 将带返回值的消费者业务方法转换为 Handle，返回值写回请求方
+// 消费失败时按原有策略重试，请求方继续等待直到成功、进入死信或超时
+func Reply(h ReplyHandle) Handle {
+	return func(ctx context.Context, msg []byte) error {
+		res, err := h(ctx, msg)
+		if err != nil {
+			return err
+		}
+		w, ok := ReplyWriterFromContext(ctx)
+		if !ok {
+			return NonRetryable(ReplyNotSupported)
+		}
+		if _, err := w.Write(res); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// Call 类型化请求-响应，响应使用与主题相同的编解码器
+// 主题的 client 需实现 Requester
+func Call[T, R any](ctx context.Context, t *Topic[T], msg T, timeout time.Duration, opts ...PublishOption) (R, error) {
+	var zero R
+	r, ok := t.client.(Requester)
+	if !ok {
+		return zero, ReplyNotSupported
+	}
+	data, err := t.codec.Marshal(msg)
+	if err != nil {
+		return zero, errors.Wrap(MessageEncodeFailed, err.Error())
+	}
+	res, err := r.Request(ctx, t.config, data, timeout, opts...)
+	if err != nil {
+		return zero, err
+	}
+	reply, err := decodeValue[R](t.codec, res)
+	if err != nil {
+		return zero, errors.Wrap(MessageDecodeFailed, err.Error())
+	}
+	return reply, nil
+}
+
+// HandleReply 将类型化的带返回值处理函数转换为 Handle
+func HandleReply[T, R any](t *Topic[T], handle func(ctx context.Context, msg T) (R, error)) Handle {
+	return Reply(func(ctx context.Context, data []byte) ([]byte, error) {
+		msg, err := t.decode(data)
+		if err != nil {
+			return nil, NonRetryable(errors.Wrap(MessageDecodeFailed, err.Error()))
+		}
+		reply, err := handle(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		res, err := t.codec.Marshal(reply)
+		if err != nil {
+			return nil, NonRetryable(errors.Wrap(MessageEncodeFailed, err.Error()))
+		}
+		return res, nil
+	})
+}
+
+// SubscribeReply 注册类型化的带返回值消费者
+func SubscribeReply[T, R any](t *Topic[T], server Server, handle func(ctx context.Context, msg T) (R, error)) error {
+	return server.ConsumerNormalRegister(t.config, HandleReply(t, handle))
+}
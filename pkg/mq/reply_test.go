@@ -0,0 +1,69 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeRequester 同步调用消费者并返回响应
+type fakeRequester struct {
+	fakeClient
+	handle Handle
+}
+
+func (f *fakeRequester) Request(ctx context.Context, b *MessageConfig, msg []byte, timeout time.Duration, opts ...PublishOption) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.handle(NewReplyContext(ctx, &buf), msg); err != nil {
+		return nil, errors.Join(RequestFailed, err)
+	}
+	return buf.Bytes(), nil
+}
+
+type orderQuote struct {
+	OrderID string `json:"order_id"`
+	Price   int64  `json:"price"`
+}
+
+func TestCall(t *testing.T) {
+	cfg := &MessageConfig{Key: "order_quote"}
+	client := &fakeRequester{}
+	topic := NewTopic[orderCreated](client, cfg, nil)
+	client.handle = HandleReply(topic, func(ctx context.Context, msg orderCreated) (*orderQuote, error) {
+		if msg.Amount < 0 {
+			return nil, NonRetryable(errors.New("invalid amount"))
+		}
+		return &orderQuote{OrderID: msg.OrderID, Price: msg.Amount * 2}, nil
+	})
+
+	quote, err := Call[orderCreated, *orderQuote](context.Background(), topic, orderCreated{OrderID: "o1", Amount: 50}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.OrderID != "o1" || quote.Price != 100 {
+		t.Fatalf("unexpected reply: %+v", quote)
+	}
+
+	_, err = Call[orderCreated, *orderQuote](context.Background(), topic, orderCreated{OrderID: "o2", Amount: -1}, time.Second)
+	if !errors.Is(err, RequestFailed) {
+		t.Fatalf("expected RequestFailed, got %v", err)
+	}
+}
+
+func TestCallNotSupported(t *testing.T) {
+	topic := NewTopic[orderCreated](&fakeClient{}, &MessageConfig{Key: "order_quote"}, nil)
+	if _, err := Call[orderCreated, orderQuote](context.Background(), topic, orderCreated{}, time.Second); !errors.Is(err, ReplyNotSupported) {
+		t.Fatalf("expected ReplyNotSupported, got %v", err)
+	}
+}
+
+func TestReplyWithoutWriter(t *testing.T) {
+	h := Reply(func(ctx context.Context, msg []byte) ([]byte, error) {
+		return msg, nil
+	})
+	if err := h(context.Background(), []byte("x")); !errors.Is(err, ReplyNotSupported) || !IsNonRetryable(err) {
+		t.Fatalf("expected non retryable ReplyNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package mq
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// BackoffType 重试退避曲线
+type BackoffType string
+
+const (
+	BackoffFixed       BackoffType = "fixed"       // 固定间隔
+	BackoffLinear      BackoffType = "linear"      // 线性增长
+	BackoffExponential BackoffType = "exponential" // 指数增长
+)
+
+// RetryPolicy 重试策略
+// 为 nil 时沿用各消息队列自身的默认重试行为
+type RetryPolicy struct {
+	MaxRetry int           `json:"max_retry" yaml:"max_retry"` // 最大重试次数，超过后进入死信
+	Backoff  BackoffType   `json:"backoff" yaml:"backoff"`     // 退避曲线，默认 exponential
+	Delay    time.Duration `json:"delay" yaml:"delay"`         // 初始重试间隔，默认 1s
+	MaxDelay time.Duration `json:"max_delay" yaml:"max_delay"` // 最大重试间隔，0 表示不限制
+
+	DeadLetterQueue string `json:"dead_letter_queue" yaml:"dead_letter_queue"` // 死信队列名称，为空时死信留在原队列
+}
+
+// deadLetterQueue 返回消息配置的死信队列名称
+func deadLetterQueue(b *MessageConfig) string {
+	if b.Retry == nil {
+		return ""
+	}
+	return b.Retry.DeadLetterQueue
+}
+
+// NextDelay 计算第 n 次重试（从 0 开始）前的等待时间
+func (p *RetryPolicy) NextDelay(n int) time.Duration {
+	delay := p.Delay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	if n < 0 {
+		n = 0
+	}
+	var d time.Duration
+	switch p.Backoff {
+	case BackoffFixed:
+		d = delay
+	case BackoffLinear:
+		d = delay * time.Duration(n+1)
+	default:
+		f := float64(delay) * math.Pow(2, float64(n))
+		if f > math.MaxInt64 {
+			d = time.Duration(math.MaxInt64)
+		} else {
+			d = time.Duration(f)
+		}
+	}
+	if d <= 0 {
+		d = time.Duration(math.MaxInt64)
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// nonRetryableError 不可重试错误包装
+type nonRetryableError struct {
+	err error
+}
+
+func (e *nonRetryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nonRetryableError) Unwrap() error {
+	return e.err
+}
+
+func (e *nonRetryableError) Is(target error) bool {
+	return target == SkipRetry
+}
+
+// NonRetryable 将错误标记为不可重试，消费者返回后消息直接进入死信
+func NonRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &nonRetryableError{err: err}
+}
+
+// IsNonRetryable 判断错误是否不可重试
+func IsNonRetryable(err error) bool {
+	return errors.Is(err, SkipRetry)
+}
+
+// requeueError 稍后重新投递的错误包装，不计入失败次数
+type requeueError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *requeueError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requeueError) Unwrap() error {
+	return e.err
+}
+
+// Requeue 将错误标记为稍后重新投递，不计入重试次数，适用于限流、乱序等暂时无法处理的场景
+func Requeue(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &requeueError{err: err, delay: delay}
+}
+
+// RequeueDelay 判断错误是否需要重新投递并返回等待时间
+func RequeueDelay(err error) (time.Duration, bool) {
+	var e *requeueError
+	if errors.As(err, &e) {
+		return e.delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,49 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNextDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		n      int
+		want   time.Duration
+	}{
+		{"fixed", RetryPolicy{Backoff: BackoffFixed, Delay: 2 * time.Second}, 3, 2 * time.Second},
+		{"linear", RetryPolicy{Backoff: BackoffLinear, Delay: time.Second}, 2, 3 * time.Second},
+		{"exponential", RetryPolicy{Backoff: BackoffExponential, Delay: time.Second}, 3, 8 * time.Second},
+		{"default_exponential", RetryPolicy{}, 1, 2 * time.Second},
+		{"max_delay", RetryPolicy{Delay: time.Second, MaxDelay: 5 * time.Second}, 10, 5 * time.Second},
+		{"overflow", RetryPolicy{Delay: time.Second, MaxDelay: time.Hour}, 200, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.NextDelay(tt.n); got != tt.want {
+				t.Errorf("NextDelay(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNonRetryable(t *testing.T) {
+	base := errors.New("bad payload")
+	err := fmt.Errorf("handle: %w", NonRetryable(base))
+
+	if !IsNonRetryable(err) {
+		t.Error("wrapped NonRetryable error should be non-retryable")
+	}
+	if !errors.Is(err, base) {
+		t.Error("NonRetryable should keep the original error in chain")
+	}
+	if IsNonRetryable(base) {
+		t.Error("plain error should be retryable")
+	}
+	if NonRetryable(nil) != nil {
+		t.Error("NonRetryable(nil) should be nil")
+	}
+}
@@ -0,0 +1,69 @@
+package mq
+
+import (
+	"context"
+	"time"
+)
+
+// TaskState 任务状态
+type TaskState string
+
+const (
+	TaskStatePending   TaskState = "pending"   // 等待处理，包括延时任务
+	TaskStateActive    TaskState = "active"    // 处理中
+	TaskStateRetry     TaskState = "retry"     // 等待重试
+	TaskStateCompleted TaskState = "completed" // 处理成功
+	TaskStateFailed    TaskState = "failed"    // 处理失败（进入死信）
+	TaskStateCanceled  TaskState = "canceled"  // 已取消
+)
+
+// TaskProgress 任务进度
+type TaskProgress struct {
+	Percent   float64   `json:"percent"`    // 进度百分比，0-100
+	Message   string    `json:"message"`    // 进度说明
+	UpdatedAt time.Time `json:"updated_at"` // 更新时间
+}
+
+// TaskStatus 任务状态详情
+type TaskStatus struct {
+	ID            string        `json:"id"`
+	Key           string        `json:"key"`
+	Queue         string        `json:"queue"`
+	State         TaskState     `json:"state"`
+	Retried       int           `json:"retried"`
+	MaxRetry      int           `json:"max_retry"`
+	LastErr       string        `json:"last_err"`
+	Result        []byte        `json:"result"`   // 处理成功后的结果，见 Reply
+	Progress      *TaskProgress `json:"progress"` // 最近一次上报的进度，未上报时为 nil
+	NextProcessAt time.Time     `json:"next_process_at"`
+	CompletedAt   time.Time     `json:"completed_at"`
+}
+
+// TaskTracker 任务状态查询与取消
+type TaskTracker interface {
+	// TaskStatus 查询任务状态，任务不存在时返回 TaskNotFound
+	TaskStatus(ctx context.Context, b *MessageConfig, id string) (*TaskStatus, error)
+	// CancelTask 取消任务，未开始的任务直接删除，处理中的任务取消其 context 且不再重试
+	CancelTask(ctx context.Context, b *MessageConfig, id string) error
+}
+
+// ProgressReporter 任务进度上报
+type ProgressReporter interface {
+	Report(ctx context.Context, percent float64, message string) error
+}
+
+type progressReporterKey struct{}
+
+// NewProgressContext 将进度上报器放入 context，由消息队列实现在消费时调用
+func NewProgressContext(ctx context.Context, r ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, r)
+}
+
+// ReportProgress 在 Handle 中上报任务进度，消息队列不支持时忽略
+func ReportProgress(ctx context.Context, percent float64, message string) error {
+	r, ok := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	if !ok {
+		return nil
+	}
+	return r.Report(ctx, percent, message)
+}
@@ -0,0 +1,54 @@
+package mq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+type recordReporter struct {
+	percent float64
+	message string
+}
+
+func (r *recordReporter) Report(ctx context.Context, percent float64, message string) error {
+	r.percent, r.message = percent, message
+	return nil
+}
+
+func TestReportProgress(t *testing.T) {
+	if err := ReportProgress(context.Background(), 50, "half"); err != nil {
+		t.Fatalf("report without reporter should be ignored, got %v", err)
+	}
+	r := &recordReporter{}
+	ctx := NewProgressContext(context.Background(), r)
+	if err := ReportProgress(ctx, 50, "half"); err != nil {
+		t.Fatal(err)
+	}
+	if r.percent != 50 || r.message != "half" {
+		t.Fatalf("unexpected progress: %+v", r)
+	}
+}
+
+func TestAsynqTaskState(t *testing.T) {
+	cases := []struct {
+		state    asynq.TaskState
+		canceled bool
+		want     TaskState
+	}{
+		{asynq.TaskStatePending, false, TaskStatePending},
+		{asynq.TaskStateScheduled, false, TaskStatePending},
+		{asynq.TaskStateActive, false, TaskStateActive},
+		{asynq.TaskStateRetry, false, TaskStateRetry},
+		{asynq.TaskStateCompleted, false, TaskStateCompleted},
+		{asynq.TaskStateArchived, false, TaskStateFailed},
+		{asynq.TaskStateArchived, true, TaskStateCanceled},
+		{asynq.TaskStateCompleted, true, TaskStateCompleted},
+	}
+	for _, c := range cases {
+		if got := asynqTaskState(c.state, c.canceled); got != c.want {
+			t.Errorf("asynqTaskState(%v, %v) = %s, want %s", c.state, c.canceled, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package mq
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/go-kratos/kratos/v2/encoding/json"
+	"github.com/go-kratos/kratos/v2/encoding/proto"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+)
+
+// 预定义编解码器
+var (
+	JSONCodec  = encoding.GetCodec(json.Name)  // JSON，同时兼容 protobuf 消息（protojson）
+	ProtoCodec = encoding.GetCodec(proto.Name) // protobuf 二进制
+)
+
+// Topic 类型化主题，封装消息的编解码
+type Topic[T any] struct {
+	client Client
+	config *MessageConfig
+	codec  encoding.Codec
+	log    *log.Helper
+}
+
+type topicOptions struct {
+	logger log.Logger
+}
+
+// TopicOption 类型化主题选项
+type TopicOption func(*topicOptions)
+
+// WithTopicLogger 设置记录解码失败等错误的日志，默认使用 kratos 全局日志
+func WithTopicLogger(logger log.Logger) TopicOption {
+	return func(o *topicOptions) {
+		o.logger = logger
+	}
+}
+
+// NewTopic 创建类型化主题
+// client 为生产消息使用的客户端（实现 Producer 时支持 ctx 与全部生产选项），只消费时可传 nil，此时生产返回 TopicClientMissing；
+// codec 为 nil 时使用 JSONCodec
+func NewTopic[T any](client Client, cfg *MessageConfig, codec encoding.Codec, opts ...TopicOption) *Topic[T] {
+	if codec == nil {
+		codec = JSONCodec
+	}
+	o := &topicOptions{logger: log.GetLogger()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Topic[T]{
+		client: client,
+		config: cfg,
+		codec:  codec,
+		log:    log.NewHelper(log.With(o.logger, "module", "mq.topic")),
+	}
+}
+
+// Config 返回主题对应的消息配置
+func (t *Topic[T]) Config() *MessageConfig {
+	return t.config
+}
+
+// Publish 生产消息
+// client 实现 Producer 时透传 ctx 与选项，否则仅支持延迟选项
+func (t *Topic[T]) Publish(ctx context.Context, msg T, opts ...PublishOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if t.client == nil {
+		return TopicClientMissing
+	}
+	data, err := t.codec.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(MessageEncodeFailed, err.Error())
+	}
+	if p, ok := t.client.(Producer); ok {
+		_, err = p.Publish(ctx, t.config, data, opts...)
+		return err
+	}
+	o := NewPublishOptions(opts...)
+	switch {
+	case !o.ProcessAt.IsZero() || o.UniqueKey != "" || o.UniqueTTL > 0 || o.Queue != "" || o.Priority != "" ||
+		o.MaxRetry != nil || o.Timeout > 0 || o.Retention > 0 || len(o.Headers) > 0 || o.OrderingKey != "":
+		return ProducerNotSupported
+	case o.Delay > 0:
+		return t.client.ProducerDelayMessage(t.config, data, o.Delay)
+	default:
+		return t.client.ProducerNormalMessage(t.config, data)
+	}
+}
+
+// PublishAfter 生产延时消息
+func (t *Topic[T]) PublishAfter(ctx context.Context, msg T, d time.Duration, opts ...PublishOption) error {
+	return t.Publish(ctx, msg, append(opts, WithDelay(d))...)
+}
+
+// Subscribe 注册类型化消费者
+// 消息解码失败时记录错误日志并返回不可重试的错误，asynq 中消息直接进入死信
+func (t *Topic[T]) Subscribe(server Server, handle func(ctx context.Context, msg T) error) error {
+	return server.ConsumerNormalRegister(t.config, t.Handle(handle))
+}
+
+// Handle 将类型化处理函数转换为 Handle
+func (t *Topic[T]) Handle(handle func(ctx context.Context, msg T) error) Handle {
+	return func(ctx context.Context, data []byte) error {
+		msg, err := t.decode(data)
+		if err != nil {
+			id := ""
+			if info, ok := MessageFromContext(ctx); ok {
+				id = info.ID
+			}
+			t.log.Error("消息解码失败，不再重试,key:", t.config.Key, "id:", id, "err:", err)
+			return NonRetryable(errors.Wrap(MessageDecodeFailed, err.Error()))
+		}
+		return handle(ctx, msg)
+	}
+}
+
+// decode 解码消息
+func (t *Topic[T]) decode(data []byte) (T, error) {
+	return decodeValue[T](t.codec, data)
+}
+
+// decodeValue 解码为 V，V 为指针类型（如 protobuf 消息）时自动分配
+func decodeValue[V any](codec encoding.Codec, data []byte) (V, error) {
+	var v V
+	if rt := reflect.TypeFor[V](); rt.Kind() == reflect.Pointer {
+		v = reflect.New(rt.Elem()).Interface().(V)
+		err := codec.Unmarshal(data, v)
+		return v, err
+	}
+	err := codec.Unmarshal(data, &v)
+	return v, err
+}
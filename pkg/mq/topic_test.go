@@ -0,0 +1,132 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fakeClient 记录最后一次生产的消息
+type fakeClient struct {
+	msg   []byte
+	delay time.Duration
+}
+
+func (f *fakeClient) ProducerNormalMessage(b *MessageConfig, msg []byte) error {
+	f.msg = msg
+	return nil
+}
+
+func (f *fakeClient) ProducerDelayMessage(b *MessageConfig, msg []byte, t time.Duration) error {
+	f.msg, f.delay = msg, t
+	return nil
+}
+
+type orderCreated struct {
+	OrderID string `json:"order_id"`
+	Amount  int64  `json:"amount"`
+}
+
+func TestTopicJSON(t *testing.T) {
+	client := &fakeClient{}
+	cfg := &MessageConfig{Key: "order_created"}
+	topic := NewTopic[orderCreated](client, cfg, nil)
+
+	if err := topic.PublishAfter(context.Background(), orderCreated{OrderID: "o1", Amount: 100}, time.Minute); err != nil {
+		t.Fatalf("PublishAfter failed: %v", err)
+	}
+	if string(client.msg) != `{"order_id":"o1","amount":100}` || client.delay != time.Minute {
+		t.Fatalf("unexpected message %s delay %v", client.msg, client.delay)
+	}
+
+	var got orderCreated
+	handle := topic.Handle(func(ctx context.Context, msg orderCreated) error {
+		got = msg
+		return nil
+	})
+	if err := handle(context.Background(), client.msg); err != nil {
+		t.Fatalf("handle failed: %v", err)
+	}
+	if got.OrderID != "o1" || got.Amount != 100 {
+		t.Errorf("decoded = %+v", got)
+	}
+}
+
+func TestTopicProto(t *testing.T) {
+	client := &fakeClient{}
+	topic := NewTopic[*wrapperspb.StringValue](client, &MessageConfig{Key: "proto"}, ProtoCodec)
+
+	if err := topic.Publish(context.Background(), wrapperspb.String("hello")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	var got string
+	handle := topic.Handle(func(ctx context.Context, msg *wrapperspb.StringValue) error {
+		got = msg.GetValue()
+		return nil
+	})
+	if err := handle(context.Background(), client.msg); err != nil {
+		t.Fatalf("handle failed: %v", err)
+	}
+	if got != "hello" {
+		t.Errorf("decoded = %q, want hello", got)
+	}
+}
+
+// recordLogger 记录日志内容
+type recordLogger struct {
+	lines []string
+}
+
+func (r *recordLogger) Log(level log.Level, keyvals ...any) error {
+	r.lines = append(r.lines, level.String()+" "+fmt.Sprint(keyvals...))
+	return nil
+}
+
+func TestTopicDecodeFailureIsNonRetryable(t *testing.T) {
+	logger := &recordLogger{}
+	topic := NewTopic[orderCreated](nil, &MessageConfig{Key: "order_created"}, JSONCodec, WithTopicLogger(logger))
+	called := false
+	handle := topic.Handle(func(ctx context.Context, msg orderCreated) error {
+		called = true
+		return nil
+	})
+	err := handle(context.Background(), []byte("not json"))
+	if called {
+		t.Error("handler should not be called on decode failure")
+	}
+	if !IsNonRetryable(err) || !errors.Is(err, MessageDecodeFailed) {
+		t.Errorf("err = %v, want non-retryable MessageDecodeFailed", err)
+	}
+	if len(logger.lines) != 1 || !strings.HasPrefix(logger.lines[0], "ERROR") || !strings.Contains(logger.lines[0], "order_created") {
+		t.Errorf("decode failure not logged: %q", logger.lines)
+	}
+}
+
+func TestTopicPublishCanceled(t *testing.T) {
+	client := &fakeClient{}
+	topic := NewTopic[orderCreated](client, &MessageConfig{Key: "order_created"}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := topic.Publish(ctx, orderCreated{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if client.msg != nil {
+		t.Error("canceled publish should not produce a message")
+	}
+}
+
+func TestTopicPublishWithoutClient(t *testing.T) {
+	topic := NewTopic[orderCreated](nil, &MessageConfig{Key: "order_created"}, JSONCodec)
+	if err := topic.Publish(context.Background(), orderCreated{OrderID: "1"}); !errors.Is(err, TopicClientMissing) {
+		t.Errorf("Publish err = %v, want TopicClientMissing", err)
+	}
+	if err := topic.PublishAfter(context.Background(), orderCreated{OrderID: "1"}, time.Second); !errors.Is(err, TopicClientMissing) {
+		t.Errorf("PublishAfter err = %v, want TopicClientMissing", err)
+	}
+}
@@ -0,0 +1,67 @@
+package mq
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/fzf-labs/kratos-contrib/pkg/mq"
+
+// propagator 通过消息头传递链路信息
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// startProducerSpan 创建生产者 span 并将链路信息写入消息头
+func startProducerSpan(ctx context.Context, system string, b *MessageConfig, o *PublishOptions) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "mq.publish "+b.Key,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", system),
+			attribute.String("messaging.destination.name", b.Key),
+		),
+	)
+	if o.Headers == nil {
+		o.Headers = make(map[string]string)
+	}
+	propagator.Inject(ctx, propagation.MapCarrier(o.Headers))
+	return ctx, span
+}
+
+// endSpan 记录错误并结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Tracing 消费者链路追踪中间件，从消息头恢复生产者的链路
+func Tracing() Middleware {
+	return func(next Handle) Handle {
+		return func(ctx context.Context, msg []byte) error {
+			info, ok := MessageFromContext(ctx)
+			if !ok {
+				return next(ctx, msg)
+			}
+			if info.Headers != nil {
+				ctx = propagator.Extract(ctx, propagation.MapCarrier(info.Headers))
+			}
+			ctx, span := otel.Tracer(tracerName).Start(ctx, "mq.consume "+info.Key,
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("messaging.destination.name", info.Key),
+					attribute.String("messaging.message.id", info.ID),
+					attribute.Int("messaging.retry_count", info.Retried),
+				),
+			)
+			err := next(ctx, msg)
+			endSpan(span, err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package sse
+
+import "iter"
+
+// AnthropicStream 以 Anthropic Messages 流式事件格式写入响应
+// 事件顺序：message_start、content_block_start/delta/stop（可多个）、message_delta、message_stop
+type AnthropicStream struct {
+	w         *Writer
+	id        string
+	model     string
+	started   bool
+	finished  bool
+	block     int    // 当前内容块索引，-1 表示没有打开的内容块
+	blockType string // 当前内容块类型：text、tool_use
+	next      int    // 下一个内容块索引
+	usage     ChatUsage
+	stop      string // WriteDelta 收到的结束原因，等待 Usage 或 Done 时写入
+}
+
+// NewAnthropicStream 创建 Anthropic 格式的流式响应，id 如 msg_xxx
+func NewAnthropicStream(w *Writer, id, model string) *AnthropicStream {
+	return &AnthropicStream{
+		w:     w,
+		id:    id,
+		model: model,
+		block: -1,
+	}
+}
+
+// Start 写入 message_start，未调用时在写入第一个内容块前自动调用
+func (s *AnthropicStream) Start(inputTokens int) error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	s.usage.PromptTokens = inputTokens
+	return s.w.WriteEventWithName("message_start", map[string]any{
+		"type": "message_start",
+		"message": map[string]any{
+			"id":            s.id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         s.model,
+			"content":       []any{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         map[string]int{"input_tokens": inputTokens, "output_tokens": 0},
+		},
+	})
+}
+
+// WriteText 写入文本增量，当前不是文本块时开启新的文本块
+func (s *AnthropicStream) WriteText(text string) error {
+	if s.blockType != "text" {
+		if err := s.startBlock("text", map[string]any{"type": "text", "text": ""}); err != nil {
+			return err
+		}
+	}
+	return s.writeDelta(map[string]any{"type": "text_delta", "text": text})
+}
+
+// WriteToolUse 开启工具调用块，参数通过 WriteToolInput 写入
+func (s *AnthropicStream) WriteToolUse(id, name string) error {
+	return s.startBlock("tool_use", map[string]any{
+		"type":  "tool_use",
+		"id":    id,
+		"name":  name,
+		"input": map[string]any{},
+	})
+}
+
+// WriteToolInput 写入当前工具调用块的 JSON 参数片段
+func (s *AnthropicStream) WriteToolInput(partialJSON string) error {
+	if s.blockType != "tool_use" {
+		return nil
+	}
+	return s.writeDelta(map[string]any{"type": "input_json_delta", "partial_json": partialJSON})
+}
+
+// Ping 写入 ping 事件
+func (s *AnthropicStream) Ping() error {
+	return s.w.WriteEventWithName("ping", map[string]string{"type": "ping"})
+}
+
+// Finish 关闭当前内容块并写入 message_delta 与 message_stop
+// stopReason 为 Anthropic 取值，如 end_turn、max_tokens、tool_use
+func (s *AnthropicStream) Finish(stopReason string, outputTokens int) error {
+	if s.finished {
+		return nil
+	}
+	if err := s.Start(0); err != nil {
+		return err
+	}
+	if err := s.stopBlock(); err != nil {
+		return err
+	}
+	s.finished = true
+	if err := s.w.WriteEventWithName("message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": map[string]int{"output_tokens": outputTokens},
+	}); err != nil {
+		return err
+	}
+	return s.w.WriteEventWithName("message_stop", map[string]string{"type": "message_stop"})
+}
+
+// WriteError 写入 Anthropic 格式的 error 事件
+func (s *AnthropicStream) WriteError(e *LLMError) error {
+	return s.w.WriteEventWithName("error", map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": e.Type, "message": e.Message},
+	})
+}
+
+// WriteDelta 写入与厂商无关的增量，结束原因按 OpenAI 取值映射
+// OpenAI 的 Usage 在结束原因之后到达，因此收到结束原因时只关闭内容块，收到 Usage 或调用 Done 时再写入 message_delta
+func (s *AnthropicStream) WriteDelta(d ChatDelta) error {
+	if d.Usage != nil {
+		if d.Usage.PromptTokens > 0 {
+			s.usage.PromptTokens = d.Usage.PromptTokens
+		}
+		if d.Usage.CompletionTokens > 0 {
+			s.usage.CompletionTokens = d.Usage.CompletionTokens
+		}
+	}
+	if err := s.Start(s.usage.PromptTokens); err != nil {
+		return err
+	}
+	if d.Content != "" {
+		if err := s.WriteText(d.Content); err != nil {
+			return err
+		}
+	}
+	for _, call := range d.ToolCalls {
+		if call.ID != "" || call.Name != "" {
+			if err := s.WriteToolUse(call.ID, call.Name); err != nil {
+				return err
+			}
+		}
+		if call.Arguments != "" {
+			if err := s.WriteToolInput(call.Arguments); err != nil {
+				return err
+			}
+		}
+	}
+	if d.FinishReason != "" {
+		s.stop = mapReason(openAIToAnthropicStop, d.FinishReason)
+		if err := s.stopBlock(); err != nil {
+			return err
+		}
+	}
+	if s.stop != "" && d.Usage != nil {
+		return s.Finish(s.stop, s.usage.CompletionTokens)
+	}
+	return nil
+}
+
+// Done 结束响应，尚未调用 Finish 时使用收到的结束原因（默认 end_turn）调用
+func (s *AnthropicStream) Done() error {
+	stop := s.stop
+	if stop == "" {
+		stop = "end_turn"
+	}
+	return s.Finish(stop, s.usage.CompletionTokens)
+}
+
+// startBlock 关闭当前内容块并开启新的内容块
+func (s *AnthropicStream) startBlock(blockType string, block map[string]any) error {
+	if err := s.Start(0); err != nil {
+		return err
+	}
+	if err := s.stopBlock(); err != nil {
+		return err
+	}
+	s.block, s.blockType = s.next, blockType
+	s.next++
+	return s.w.WriteEventWithName("content_block_start", map[string]any{
+		"type":          "content_block_start",
+		"index":         s.block,
+		"content_block": block,
+	})
+}
+
+// stopBlock 关闭当前内容块
+func (s *AnthropicStream) stopBlock() error {
+	if s.block < 0 {
+		return nil
+	}
+	index := s.block
+	s.block, s.blockType = -1, ""
+	return s.w.WriteEventWithName("content_block_stop", map[string]any{
+		"type":  "content_block_stop",
+		"index": index,
+	})
+}
+
+func (s *AnthropicStream) writeDelta(delta map[string]any) error {
+	return s.w.WriteEventWithName("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": s.block,
+		"delta": delta,
+	})
+}
+
+// anthropicEvent Anthropic 流式事件
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message *struct {
+		ID    string         `json:"id"`
+		Model string         `json:"model"`
+		Role  string         `json:"role"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *LLMError       `json:"error"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// ParseAnthropic 将 Anthropic 流式事件解析为增量，收到 message_stop 时结束；收到 error 事件时返回 *LLMError
+// 工具调用按出现顺序编号，结束原因映射为 OpenAI 取值
+func ParseAnthropic(events iter.Seq2[Event, error]) iter.Seq2[ChatDelta, error] {
+	return func(yield func(ChatDelta, error) bool) {
+		var (
+			id, model   string
+			inputTokens int
+			tools       = make(map[int]int) // 内容块索引 -> 工具调用序号
+		)
+		for e, err := range events {
+			if err != nil {
+				yield(ChatDelta{}, err)
+				return
+			}
+			ev, err := Decode[anthropicEvent](e)
+			if err != nil {
+				yield(ChatDelta{}, err)
+				return
+			}
+			d := ChatDelta{ID: id, Model: model}
+			switch ev.Type {
+			case "message_start":
+				if ev.Message == nil {
+					continue
+				}
+				id, model, inputTokens = ev.Message.ID, ev.Message.Model, ev.Message.Usage.InputTokens
+				d.ID, d.Model, d.Role = id, model, ev.Message.Role
+			case "content_block_start":
+				if ev.ContentBlock == nil {
+					continue
+				}
+				switch ev.ContentBlock.Type {
+				case "text":
+					d.Content = ev.ContentBlock.Text
+				case "tool_use":
+					tools[ev.Index] = len(tools)
+					d.ToolCalls = []ToolCallDelta{{Index: tools[ev.Index], ID: ev.ContentBlock.ID, Name: ev.ContentBlock.Name}}
+				}
+			case "content_block_delta":
+				if ev.Delta == nil {
+					continue
+				}
+				switch ev.Delta.Type {
+				case "text_delta":
+					d.Content = ev.Delta.Text
+				case "input_json_delta":
+					if idx, ok := tools[ev.Index]; ok && ev.Delta.PartialJSON != "" {
+						d.ToolCalls = []ToolCallDelta{{Index: idx, Arguments: ev.Delta.PartialJSON}}
+					}
+				}
+			case "message_delta":
+				if ev.Delta != nil && ev.Delta.StopReason != "" {
+					d.FinishReason = mapReason(anthropicToOpenAIStop, ev.Delta.StopReason)
+				}
+				if ev.Usage != nil {
+					d.Usage = &ChatUsage{
+						PromptTokens:     inputTokens,
+						CompletionTokens: ev.Usage.OutputTokens,
+						TotalTokens:      inputTokens + ev.Usage.OutputTokens,
+					}
+				}
+			case "message_stop":
+				return
+			case "error":
+				if ev.Error == nil {
+					ev.Error = &LLMError{Message: "unknown error"}
+				}
+				yield(ChatDelta{}, ev.Error)
+				return
+			}
+			if !d.empty() && !yield(d, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	stdhttp "net/http"
+	"sync"
+	"time"
+)
+
+// 异步写入相关错误
+var (
+	ErrWriterOverflow = errors.New("sse: write queue overflow")
+	ErrWriterClosed   = errors.New("sse: writer closed")
+)
+
+// OverflowPolicy 异步模式下写入队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞直到队列有空位或连接断开
+	OverflowDropOldest                       // 丢弃最早的未发送事件
+	OverflowCoalesce                         // 丢弃队列中同名的事件，无同名事件时丢弃最早的事件
+	OverflowDisconnect                       // 断开连接
+)
+
+type writerOptions struct {
+	buffer       int
+	overflow     OverflowPolicy
+	writeTimeout time.Duration
+	maxLifetime  time.Duration
+
+	encodings         []string
+	compressThreshold int
+}
+
+// WriterOption Writer 选项
+type WriterOption func(*writerOptions)
+
+// WithWriterBuffer 开启异步模式，事件先放入容量为 n 的队列，由后台协程写入，慢客户端不再阻塞生产者
+func WithWriterBuffer(n int) WriterOption {
+	return func(o *writerOptions) {
+		o.buffer = n
+	}
+}
+
+// WithWriterOverflow 设置异步模式下队列已满时的处理策略，默认 OverflowBlock
+func WithWriterOverflow(p OverflowPolicy) WriterOption {
+	return func(o *writerOptions) {
+		o.overflow = p
+	}
+}
+
+// WithWriterTimeout 设置单次写入超时，超时后连接被关闭，写入返回错误
+func WithWriterTimeout(d time.Duration) WriterOption {
+	return func(o *writerOptions) {
+		o.writeTimeout = d
+	}
+}
+
+// WithWriterMaxLifetime 设置最大流式时长，到期后流式 context 以 DeadlineExceeded 结束，默认不限制
+func WithWriterMaxLifetime(d time.Duration) WriterOption {
+	return func(o *writerOptions) {
+		o.maxLifetime = d
+	}
+}
+
+func newWriterOptions(opts ...WriterOption) writerOptions {
+	o := writerOptions{compressThreshold: defaultCompressThreshold}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WriterStats Writer 统计信息
+type WriterStats struct {
+	Pending int    `json:"pending"` // 队列中待写入的事件数
+	Written uint64 `json:"written"` // 已写入的事件数
+	Dropped uint64 `json:"dropped"` // 因队列已满或连接断开丢弃的事件数
+}
+
+// init 应用选项，异步模式下启动写入协程
+func (s *Writer) init(o writerOptions) {
+	s.rc = stdhttp.NewResponseController(s.w)
+	if o.buffer > 0 || o.writeTimeout > 0 {
+		// 由 Writer 控制每次写入的截止时间，清除 http.Server WriteTimeout 设置的截止时间，避免长连接被中断；
+		// 同步且未设置写入超时时保留，防止停止读取的客户端一直阻塞生产者
+		_ = s.rc.SetWriteDeadline(time.Time{})
+	}
+	s.writeTimeout = o.writeTimeout
+	if o.buffer > 0 {
+		s.queue = &writeQueue{
+			writer: s,
+			size:   o.buffer,
+			policy: o.overflow,
+			notify: make(chan struct{}, 1),
+			space:  make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		go s.queue.run()
+	}
+}
+
+// deadline 设置单次写入的截止时间，返回清除函数
+func (s *Writer) deadline() func() {
+	if s.writeTimeout <= 0 || s.rc == nil {
+		return func() {}
+	}
+	if err := s.rc.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
+		return func() {}
+	}
+	return func() {
+		_ = s.rc.SetWriteDeadline(time.Time{})
+	}
+}
+
+// Stats 返回统计信息
+func (s *Writer) Stats() WriterStats {
+	stats := WriterStats{
+		Written: s.written.Load(),
+		Dropped: s.dropped.Load(),
+	}
+	if q := s.queue; q != nil {
+		q.mu.Lock()
+		stats.Pending = len(q.items)
+		q.mu.Unlock()
+	}
+	return stats
+}
+
+// Close 异步模式下等待队列中的事件写完并停止写入协程，开启压缩时写出压缩流结尾，返回写入过程中的错误
+// 同步且未压缩时无操作
+func (s *Writer) Close() error {
+	var err error
+	if q := s.queue; q != nil {
+		q.mu.Lock()
+		q.closing = true
+		q.mu.Unlock()
+		q.signal()
+		<-q.done
+
+		q.mu.Lock()
+		err = q.err
+		q.mu.Unlock()
+	}
+	if s.compress != nil {
+		s.mu.Lock()
+		cerr := s.compress.Close()
+		s.mu.Unlock()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// queuedWrite 待写入的事件
+type queuedWrite struct {
+	name string
+	data []byte
+}
+
+// writeQueue 有界写入队列
+type writeQueue struct {
+	writer  *Writer
+	size    int
+	policy  OverflowPolicy
+	mu      sync.Mutex
+	items   []queuedWrite
+	closing bool
+	err     error
+	notify  chan struct{} // 有新事件
+	space   chan struct{} // 队列腾出空位时关闭并替换，唤醒所有阻塞的写入
+	done    chan struct{} // 写入协程退出
+}
+
+// signal 唤醒写入协程
+func (q *writeQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// push 放入队列，队列已满时按策略处理
+func (q *writeQueue) push(ctx context.Context, name string, data []byte) error {
+	item := queuedWrite{name: name, data: data}
+	for {
+		q.mu.Lock()
+		if q.err != nil {
+			err := q.err
+			q.mu.Unlock()
+			return err
+		}
+		if q.closing {
+			q.mu.Unlock()
+			return ErrWriterClosed
+		}
+		if len(q.items) < q.size {
+			q.items = append(q.items, item)
+			q.mu.Unlock()
+			q.signal()
+			return nil
+		}
+		switch q.policy {
+		case OverflowDropOldest, OverflowCoalesce:
+			q.evict(name)
+			q.items = append(q.items, item)
+			q.mu.Unlock()
+			q.writer.dropped.Add(1)
+			q.signal()
+			return nil
+		case OverflowDisconnect:
+			q.mu.Unlock()
+			q.fail(ErrWriterOverflow)
+			return ErrWriterOverflow
+		}
+		space := q.space
+		q.mu.Unlock()
+		select {
+		case <-space:
+		case <-q.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// evict 腾出一个空位，合并策略下优先移除最近的同名事件
+func (q *writeQueue) evict(name string) {
+	idx := 0
+	if q.policy == OverflowCoalesce && name != "" {
+		for i := len(q.items) - 1; i >= 0; i-- {
+			if q.items[i].name == name {
+				idx = i
+				break
+			}
+		}
+	}
+	q.items = append(q.items[:idx], q.items[idx+1:]...)
+}
+
+// take 取出队列中的全部事件，队列为空时等待，关闭或连接断开时返回 false
+func (q *writeQueue) take() ([]queuedWrite, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			batch := q.items
+			q.items = make([]queuedWrite, 0, q.size)
+			close(q.space)
+			q.space = make(chan struct{})
+			q.mu.Unlock()
+			return batch, true
+		}
+		stop := q.closing || q.err != nil
+		q.mu.Unlock()
+		if stop {
+			return nil, false
+		}
+		select {
+		case <-q.notify:
+		case <-q.writer.ctx.Done():
+			q.fail(q.writer.ctx.Err())
+			return nil, false
+		}
+	}
+}
+
+// fail 记录错误、丢弃未写入的事件并结束流式 context，后续写入返回该错误
+func (q *writeQueue) fail(err error) {
+	q.mu.Lock()
+	if q.err != nil {
+		q.mu.Unlock()
+		return
+	}
+	q.err = err
+	q.writer.dropped.Add(uint64(len(q.items)))
+	q.items = nil
+	q.mu.Unlock()
+	q.signal()
+	if q.writer.cancel != nil {
+		q.writer.cancel(context.Canceled)
+	}
+}
+
+// run 写入协程，每批事件写完后刷新一次
+func (q *writeQueue) run() {
+	defer close(q.done)
+	s := q.writer
+	for {
+		batch, ok := q.take()
+		if !ok {
+			return
+		}
+		if err := q.write(batch); err != nil {
+			q.fail(err)
+			return
+		}
+		s.written.Add(uint64(len(batch)))
+	}
+}
+
+// write 写入一批事件
+func (q *writeQueue) write(batch []queuedWrite) error {
+	s := q.writer
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, item := range batch {
+		reset := s.deadline()
+		_, err := s.w.Write(item.data)
+		reset()
+		if err != nil {
+			s.dropped.Add(uint64(len(batch) - i))
+			return err
+		}
+	}
+	defer s.deadline()()
+	s.flusher.Flush()
+	return nil
+}
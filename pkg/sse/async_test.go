@@ -0,0 +1,173 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowResponseWriter 写入前等待放行，模拟慢客户端
+type slowResponseWriter struct {
+	*mockResponseWriter
+	gate      chan struct{}
+	mu        sync.Mutex
+	deadlines []time.Time
+}
+
+func (s *slowResponseWriter) Write(data []byte) (int, error) {
+	<-s.gate
+	return s.mockResponseWriter.Write(data)
+}
+
+func (s *slowResponseWriter) SetWriteDeadline(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadlines = append(s.deadlines, t)
+	return nil
+}
+
+func newAsyncTestWriter(opts ...WriterOption) (*Writer, *slowResponseWriter) {
+	slow := &slowResponseWriter{mockResponseWriter: newMockResponseWriter(), gate: make(chan struct{})}
+	ctx := &streamContext{values: context.Background(), done: make(chan struct{})}
+	w := &Writer{w: slow, flusher: slow, ctx: ctx, cancel: ctx.cancel}
+	w.init(newWriterOptions(opts...))
+	return w, slow
+}
+
+// waitPending 等待写入协程取走第一个事件并阻塞在写入上
+func waitPending(t *testing.T, w *Writer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for w.Stats().Pending != n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if w.Stats().Pending != n {
+		t.Fatalf("pending = %d, want %d", w.Stats().Pending, n)
+	}
+}
+
+func TestAsyncWriterBlock(t *testing.T) {
+	w, slow := newAsyncTestWriter(WithWriterBuffer(1))
+	_ = w.WriteRawEvent("1")
+	waitPending(t, w, 0)
+	_ = w.WriteRawEvent("2")
+
+	done := make(chan error, 1)
+	go func() { done <- w.WriteRawEvent("3") }()
+	select {
+	case <-done:
+		t.Fatal("write should block while queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(slow.gate)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if slow.Body() != "data: 1\n\ndata: 2\n\ndata: 3\n\n" || w.Stats().Written != 3 {
+		t.Fatalf("unexpected body %q stats %+v", slow.Body(), w.Stats())
+	}
+	if err := w.WriteRawEvent("4"); !errors.Is(err, ErrWriterClosed) {
+		t.Fatalf("expected ErrWriterClosed, got %v", err)
+	}
+}
+
+func TestAsyncWriterOverflow(t *testing.T) {
+	t.Run("drop oldest", func(t *testing.T) {
+		w, slow := newAsyncTestWriter(WithWriterBuffer(2), WithWriterOverflow(OverflowDropOldest))
+		_ = w.WriteRawEvent("1")
+		waitPending(t, w, 0)
+		for _, s := range []string{"2", "3", "4"} {
+			if err := w.WriteRawEvent(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		close(slow.gate)
+		_ = w.Close()
+		if slow.Body() != "data: 1\n\ndata: 3\n\ndata: 4\n\n" || w.Stats().Dropped != 1 {
+			t.Fatalf("unexpected body %q stats %+v", slow.Body(), w.Stats())
+		}
+	})
+	t.Run("coalesce", func(t *testing.T) {
+		w, slow := newAsyncTestWriter(WithWriterBuffer(2), WithWriterOverflow(OverflowCoalesce))
+		_ = w.WriteEventWithName("progress", 0)
+		waitPending(t, w, 0)
+		_ = w.WriteEventWithName("progress", 1)
+		_ = w.WriteEventWithName("message", "hi")
+		_ = w.WriteEventWithName("progress", 2)
+		close(slow.gate)
+		_ = w.Close()
+		want := "event: progress\ndata: 0\n\nevent: message\ndata: \"hi\"\n\nevent: progress\ndata: 2\n\n"
+		if slow.Body() != want {
+			t.Fatalf("body = %q, want %q", slow.Body(), want)
+		}
+	})
+	t.Run("disconnect", func(t *testing.T) {
+		w, slow := newAsyncTestWriter(WithWriterBuffer(1), WithWriterOverflow(OverflowDisconnect))
+		_ = w.WriteRawEvent("1")
+		waitPending(t, w, 0)
+		_ = w.WriteRawEvent("2")
+		if err := w.WriteRawEvent("3"); !errors.Is(err, ErrWriterOverflow) {
+			t.Fatalf("expected ErrWriterOverflow, got %v", err)
+		}
+		select {
+		case <-w.Context().Done():
+		default:
+			t.Fatal("context should be canceled")
+		}
+		close(slow.gate)
+		if err := w.Close(); !errors.Is(err, ErrWriterOverflow) {
+			t.Fatalf("expected ErrWriterOverflow, got %v", err)
+		}
+		if w.Stats().Dropped != 1 {
+			t.Fatalf("unexpected stats %+v", w.Stats())
+		}
+	})
+}
+
+// 同步且未设置写入超时时保留 http.Server 的截止时间，异步模式下清除
+func TestWriterServerDeadline(t *testing.T) {
+	slow := &slowResponseWriter{mockResponseWriter: newMockResponseWriter(), gate: make(chan struct{})}
+	close(slow.gate)
+	w := &Writer{w: slow, flusher: slow, ctx: context.Background()}
+	w.init(newWriterOptions())
+	if err := w.WriteComment("ping"); err != nil {
+		t.Fatal(err)
+	}
+	slow.mu.Lock()
+	if len(slow.deadlines) != 0 {
+		t.Fatalf("sync writer should keep the server deadline, got %v", slow.deadlines)
+	}
+	slow.mu.Unlock()
+
+	async, slow := newAsyncTestWriter(WithWriterBuffer(1))
+	close(slow.gate)
+	defer async.Close()
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	if len(slow.deadlines) != 1 || !slow.deadlines[0].IsZero() {
+		t.Fatalf("async writer should clear the server deadline, got %v", slow.deadlines)
+	}
+}
+
+func TestWriterTimeout(t *testing.T) {
+	w, slow := newAsyncTestWriter(WithWriterTimeout(time.Second))
+	close(slow.gate)
+	if err := w.WriteComment("ping"); err != nil {
+		t.Fatal(err)
+	}
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	// 创建时清除 Server 的截止时间，写入前设置截止时间，写入后清除
+	if len(slow.deadlines) != 3 || !slow.deadlines[0].IsZero() || slow.deadlines[1].IsZero() || !slow.deadlines[2].IsZero() {
+		t.Fatalf("unexpected deadlines %v", slow.deadlines)
+	}
+	if !strings.HasPrefix(slow.Body(), ": ping") {
+		t.Fatalf("unexpected body %q", slow.Body())
+	}
+}
@@ -0,0 +1,133 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// BackplaneHandler 接收其他实例（包括本实例）发布的事件
+type BackplaneHandler func(topic string, e StoredEvent)
+
+// Backplane 跨实例广播通道，Hub 发布的事件经由 Backplane 到达所有实例
+type Backplane interface {
+	// Publish 发布事件，事件 ID 原样传递，保证各实例的 Last-Event-ID 一致
+	Publish(ctx context.Context, topic string, e StoredEvent) error
+	// Subscribe 接收所有主题的事件，阻塞直到 ctx 取消
+	Subscribe(ctx context.Context, handler BackplaneHandler) error
+}
+
+// MemoryBackplane 内存 Backplane，同一进程内的多个 Hub 共享，用于测试
+type MemoryBackplane struct {
+	mu       sync.RWMutex
+	handlers map[*BackplaneHandler]struct{}
+}
+
+var _ Backplane = (*MemoryBackplane)(nil)
+
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{handlers: make(map[*BackplaneHandler]struct{})}
+}
+
+// Publish 同步分发给所有订阅者
+func (m *MemoryBackplane) Publish(ctx context.Context, topic string, e StoredEvent) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for h := range m.handlers {
+		(*h)(topic, e)
+	}
+	return nil
+}
+
+// Subscribe 订阅事件
+func (m *MemoryBackplane) Subscribe(ctx context.Context, handler BackplaneHandler) error {
+	h := &handler
+	m.mu.Lock()
+	m.handlers[h] = struct{}{}
+	m.mu.Unlock()
+	<-ctx.Done()
+	m.mu.Lock()
+	delete(m.handlers, h)
+	m.mu.Unlock()
+	return ctx.Err()
+}
+
+// RedisBackplane 基于 Redis pub/sub 的 Backplane
+// pub/sub 不保证离线期间的消息，实例断线期间错过的事件由客户端重连后通过 ReplayStore 补发
+type RedisBackplane struct {
+	client redis.UniversalClient
+	prefix string
+	log    *log.Helper
+}
+
+var _ Backplane = (*RedisBackplane)(nil)
+
+// RedisBackplaneOption Redis Backplane 选项
+type RedisBackplaneOption func(*redisBackplaneOptions)
+
+type redisBackplaneOptions struct {
+	logger log.Logger
+}
+
+// WithRedisBackplaneLogger 设置记录无法解析的消息等错误的日志，默认使用 kratos 全局日志
+func WithRedisBackplaneLogger(logger log.Logger) RedisBackplaneOption {
+	return func(o *redisBackplaneOptions) {
+		o.logger = logger
+	}
+}
+
+// NewRedisBackplane 创建 Redis Backplane，prefix 为空时使用 "sse:backplane:"
+func NewRedisBackplane(client redis.UniversalClient, prefix string, opts ...RedisBackplaneOption) *RedisBackplane {
+	if prefix == "" {
+		prefix = "sse:backplane:"
+	}
+	o := &redisBackplaneOptions{logger: log.GetLogger()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &RedisBackplane{
+		client: client,
+		prefix: prefix,
+		log:    log.NewHelper(log.With(o.logger, "module", "sse.backplane")),
+	}
+}
+
+// Publish 发布事件
+func (r *RedisBackplane) Publish(ctx context.Context, topic string, e StoredEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return r.client.Publish(ctx, r.prefix+topic, data).Err()
+}
+
+// Subscribe 订阅所有主题，断线后由 go-redis 自动重连
+func (r *RedisBackplane) Subscribe(ctx context.Context, handler BackplaneHandler) error {
+	pubsub := r.client.PSubscribe(ctx, r.prefix+"*")
+	defer pubsub.Close()
+	// 等待订阅确认，尽早暴露连接错误
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var e StoredEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				r.log.Error("SSE Backplane 消息解析失败，已丢弃,channel:", msg.Channel, "err:", err)
+				continue
+			}
+			handler(strings.TrimPrefix(msg.Channel, r.prefix), e)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestHubBackplane(t *testing.T) {
+	bp := NewMemoryBackplane()
+	store := NewMemoryReplayStore(10)
+	a := NewHub(WithHubBackplane(bp), WithHubReplayStore(store))
+	defer a.Close()
+	b := NewHub(WithHubBackplane(bp), WithHubReplayStore(store))
+	defer b.Close()
+
+	// 等待两个 Hub 完成 Backplane 订阅
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		bp.mu.RLock()
+		n := len(bp.handlers)
+		bp.mu.RUnlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	sa, _ := a.Subscribe("chat")
+	sb, _ := b.Subscribe("chat")
+	if err := a.Publish(context.Background(), "chat", Event{Event: "message", Data: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	id := store.streams["chat"].epoch + "-1"
+	for _, s := range []*Subscription{sa, sb} {
+		select {
+		case e := <-s.Events():
+			if e.ID != id || e.Event != "message" || string(e.Data) != `"hi"` {
+				t.Fatalf("unexpected event %+v", e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+
+	a.Close()
+	bp.mu.RLock()
+	n := len(bp.handlers)
+	bp.mu.RUnlock()
+	if n != 1 {
+		t.Fatalf("closed hub should unsubscribe, %d handlers left", n)
+	}
+}
+
+// recordLogger 记录日志内容
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *recordLogger) Log(level log.Level, keyvals ...any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, level.String()+" "+fmt.Sprint(keyvals...))
+	return nil
+}
+
+func (r *recordLogger) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.lines, "\n")
+}
+
+// 两个实例通过 Redis 广播事件，事件 ID 保持一致；无法解析的消息记录日志后丢弃
+func TestRedisBackplane(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	logger := &recordLogger{}
+	store := NewRedisReplayStore(rdb, "", 10, time.Minute)
+	a := NewHub(WithHubBackplane(NewRedisBackplane(rdb, "", WithRedisBackplaneLogger(logger))), WithHubReplayStore(store))
+	defer a.Close()
+	b := NewHub(WithHubBackplane(NewRedisBackplane(rdb, "", WithRedisBackplaneLogger(logger))), WithHubReplayStore(store))
+	defer b.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for mr.PubSubNumPat() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("backplane subscriptions = %d, want 2", mr.PubSubNumPat())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	sa, _ := a.Subscribe("chat")
+	sb, _ := b.Subscribe("chat")
+	if err := rdb.Publish(context.Background(), "sse:backplane:chat", "not json").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Publish(context.Background(), "chat", Event{Event: "message", Data: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.Since(context.Background(), "chat", "0-0")
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("stored events %+v, err = %v", stored, err)
+	}
+	for _, s := range []*Subscription{sa, sb} {
+		select {
+		case e := <-s.Events():
+			if e.ID != stored[0].ID || e.Event != "message" || string(e.Data) != `"hi"` {
+				t.Fatalf("unexpected event %+v, want id %s", e, stored[0].ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+	if got := logger.String(); strings.Count(got, "ERROR") != 2 || !strings.Contains(got, "sse:backplane:chat") {
+		t.Fatalf("invalid payload should be logged by both instances, got %q", got)
+	}
+}
@@ -0,0 +1,383 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	stdhttp "net/http"
+	"strconv"
+	"time"
+)
+
+// 客户端相关错误
+var (
+	ErrInvalidResponse = errors.New("sse: invalid response")
+	ErrEventTooLarge   = errors.New("sse: event too large")
+)
+
+// Parser SSE 事件流解析器，按 WHATWG 规范处理 data/event/id/retry 字段、注释、BOM 以及 CR/LF/CRLF 换行
+type Parser struct {
+	r       *bufio.Reader
+	maxSize int
+	started bool // 是否已处理 BOM
+	skipLF  bool // 上一行以 \r 结尾，跳过紧随的 \n
+
+	line        []byte
+	data        bytes.Buffer
+	event       string
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewParser 创建解析器，maxSize 为单个事件 data 的最大字节数，<=0 时不限制
+func NewParser(r io.Reader, maxSize int) *Parser {
+	return &Parser{
+		r:       bufio.NewReader(r),
+		maxSize: maxSize,
+	}
+}
+
+// LastEventID 返回最近一次收到的事件 ID
+func (p *Parser) LastEventID() string {
+	return p.lastEventID
+}
+
+// Retry 返回服务端通过 retry 字段设置的重连间隔，未设置时为 0
+func (p *Parser) Retry() time.Duration {
+	return p.retry
+}
+
+// Next 读取下一个事件
+// 按规范，事件 ID 为最近一次收到的 ID（不带 id 字段的事件沿用上一个 ID）；
+// data 为合法 JSON 时 Data 为 json.RawMessage，否则为 string；
+// 流结束时返回 io.EOF，末尾不完整的事件被丢弃
+func (p *Parser) Next() (Event, error) {
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+		if len(line) == 0 {
+			if e, ok := p.dispatch(); ok {
+				return e, nil
+			}
+			continue
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			p.event = string(value)
+		case "data":
+			if p.maxSize > 0 && p.data.Len()+len(value) > p.maxSize {
+				return Event{}, ErrEventTooLarge
+			}
+			p.data.Write(value)
+			p.data.WriteByte('\n')
+		case "id":
+			if bytes.IndexByte(value, 0) == -1 {
+				p.lastEventID = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				p.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// All 以迭代器形式返回解析出的事件，流结束（io.EOF）时正常结束
+func (p *Parser) All() iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		for {
+			e, err := p.Next()
+			if err != nil {
+				if err != io.EOF {
+					yield(Event{}, err)
+				}
+				return
+			}
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// dispatch 在空行处生成事件，data 为空时只重置事件名称
+func (p *Parser) dispatch() (Event, bool) {
+	defer func() {
+		p.data.Reset()
+		p.event = ""
+	}()
+	if p.data.Len() == 0 {
+		return Event{}, false
+	}
+	data := bytes.TrimSuffix(p.data.Bytes(), []byte("\n"))
+	e := Event{ID: p.lastEventID, Event: p.event}
+	if json.Valid(data) {
+		e.Data = json.RawMessage(bytes.Clone(data))
+	} else {
+		e.Data = string(data)
+	}
+	return e, true
+}
+
+// readLine 读取一行，兼容 \r、\n、\r\n 换行符
+func (p *Parser) readLine() ([]byte, error) {
+	if !p.started {
+		p.started = true
+		if bom, err := p.r.Peek(3); err == nil && bytes.Equal(bom, []byte("\xEF\xBB\xBF")) {
+			_, _ = p.r.Discard(3)
+		}
+	}
+	p.line = p.line[:0]
+	for {
+		b, err := p.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipLF {
+			p.skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+		switch b {
+		case '\n':
+			return p.line, nil
+		case '\r':
+			p.skipLF = true
+			return p.line, nil
+		}
+		if p.maxSize > 0 && len(p.line) >= p.maxSize {
+			return nil, ErrEventTooLarge
+		}
+		p.line = append(p.line, b)
+	}
+}
+
+// Decode 将事件数据解码为指定类型，string 类型可接收非 JSON 数据
+func Decode[T any](e Event) (T, error) {
+	var v T
+	switch data := e.Data.(type) {
+	case json.RawMessage:
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case string:
+		if s, ok := any(&v).(*string); ok {
+			*s = data
+			return v, nil
+		}
+		err := json.Unmarshal([]byte(data), &v)
+		return v, err
+	default:
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return v, err
+		}
+		err = json.Unmarshal(raw, &v)
+		return v, err
+	}
+}
+
+// ClientOption 客户端选项
+type ClientOption func(*Client)
+
+// WithClientHTTPClient 设置 HTTP 客户端，默认 http.DefaultClient（注意不要设置 Timeout，否则长连接会被中断）
+func WithClientHTTPClient(hc *stdhttp.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithClientHeader 设置请求头，如 Authorization
+func WithClientHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithClientBody 设置请求方法与请求体，如调用 LLM 接口时 POST JSON，重连时重新发送
+func WithClientBody(method string, body []byte) ClientOption {
+	return func(c *Client) {
+		c.method = method
+		c.body = body
+	}
+}
+
+// WithClientRetry 设置默认重连间隔，服务端通过 retry 字段设置后以服务端为准，默认 3s
+func WithClientRetry(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.retry = d
+	}
+}
+
+// WithClientMaxRetries 设置连续重连的最大次数，0 表示不重连（如 LLM 流式接口），负数表示不限制（默认）
+func WithClientMaxRetries(n int) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
+// WithClientLastEventID 设置首次连接携带的 Last-Event-ID
+func WithClientLastEventID(id string) ClientOption {
+	return func(c *Client) {
+		c.lastEventID = id
+	}
+}
+
+// WithClientMaxEventSize 设置单个事件的最大字节数，默认 1MB，<=0 表示不限制
+func WithClientMaxEventSize(n int) ClientOption {
+	return func(c *Client) {
+		c.maxEventSize = n
+	}
+}
+
+// Client SSE 客户端，断线后携带 Last-Event-ID 自动重连
+type Client struct {
+	url          string
+	method       string
+	body         []byte
+	header       stdhttp.Header
+	httpClient   *stdhttp.Client
+	retry        time.Duration
+	maxRetries   int
+	maxEventSize int
+	lastEventID  string
+}
+
+func NewClient(url string, opts ...ClientOption) *Client {
+	c := &Client{
+		url:          url,
+		method:       stdhttp.MethodGet,
+		header:       make(stdhttp.Header),
+		httpClient:   stdhttp.DefaultClient,
+		retry:        3 * time.Second,
+		maxRetries:   -1,
+		maxEventSize: 1 << 20,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// LastEventID 返回最近一次收到的事件 ID
+func (c *Client) LastEventID() string {
+	return c.lastEventID
+}
+
+// Events 连接并返回事件迭代器，跳出循环时关闭连接
+// 连接断开后按重连间隔自动重连；服务端返回 204 时正常结束；
+// 其他非 200 状态码、非 text/event-stream 响应或超过最大重连次数时返回错误并结束
+func (c *Client) Events(ctx context.Context) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		failures := 0
+		for {
+			received, err := c.stream(ctx, yield)
+			if errors.Is(err, errStopped) {
+				return
+			}
+			if ctx.Err() != nil {
+				yield(Event{}, ctx.Err())
+				return
+			}
+			if errors.Is(err, ErrInvalidResponse) {
+				yield(Event{}, err)
+				return
+			}
+			if err == nil && !received {
+				// 204 No Content：服务端要求停止重连
+				return
+			}
+			if received {
+				failures = 0
+			}
+			if c.maxRetries >= 0 && failures >= c.maxRetries {
+				// 服务端正常关闭连接时直接结束，连接失败时返回错误
+				if err != nil {
+					yield(Event{}, err)
+				}
+				return
+			}
+			failures++
+			select {
+			case <-ctx.Done():
+				yield(Event{}, ctx.Err())
+				return
+			case <-time.After(c.retry):
+			}
+		}
+	}
+}
+
+// errStopped 调用方跳出迭代
+var errStopped = errors.New("sse: stopped")
+
+// stream 建立一次连接并读取事件，received 表示连接是否成功建立
+func (c *Client) stream(ctx context.Context, yield func(Event, error) bool) (received bool, err error) {
+	var body io.Reader
+	if c.body != nil {
+		body = bytes.NewReader(c.body)
+	}
+	req, err := stdhttp.NewRequestWithContext(ctx, c.method, c.url, body)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if c.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", c.lastEventID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == stdhttp.StatusNoContent:
+		return false, nil
+	case resp.StatusCode != stdhttp.StatusOK:
+		return false, fmt.Errorf("%w: unexpected status %d", ErrInvalidResponse, resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return false, fmt.Errorf("%w: unexpected content type %q", ErrInvalidResponse, resp.Header.Get("Content-Type"))
+	}
+
+	p := NewParser(resp.Body, c.maxEventSize)
+	p.lastEventID = c.lastEventID
+	for {
+		e, err := p.Next()
+		c.lastEventID = p.LastEventID()
+		if p.Retry() > 0 {
+			c.retry = p.Retry()
+		}
+		if err != nil {
+			if errors.Is(err, ErrEventTooLarge) {
+				return true, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+			}
+			if err == io.EOF {
+				return true, nil
+			}
+			return true, err
+		}
+		if !yield(e, nil) {
+			return true, errStopped
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseAll(t *testing.T, input string) ([]Event, *Parser) {
+	t.Helper()
+	p := NewParser(strings.NewReader(input), 0)
+	var events []Event
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			return events, p
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+}
+
+func TestParser(t *testing.T) {
+	input := "\xEF\xBB\xBF: comment\r\n" +
+		"event: update\r\nid: 1\r\ndata: line1\r\ndata:line2\r\n\r\n" +
+		"data: {\"n\":1}\r\r" +
+		"retry: 1500\nretry: abc\n\n" +
+		"id: bad\x00id\ndata\n\n" +
+		"data: incomplete"
+	events, p := parseAll(t, input)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", events)
+	}
+	if e := events[0]; e.ID != "1" || e.Event != "update" || e.Data != "line1\nline2" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if e := events[1]; e.ID != "1" || e.Event != "" || string(e.Data.(json.RawMessage)) != `{"n":1}` {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	// 含 NUL 的 id 被忽略，空 data 字段产生空数据
+	if e := events[2]; e.ID != "1" || e.Data != "" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if p.Retry() != 1500*time.Millisecond {
+		t.Fatalf("retry = %v", p.Retry())
+	}
+}
+
+func TestParserRoundTrip(t *testing.T) {
+	writer, mock := newTestWriter()
+	_ = writer.SetRetry(2000)
+	_ = writer.WriteComment("heartbeat\nping")
+	_ = writer.WriteFullEvent(Event{ID: "7", Event: "message", Data: map[string]string{"text": "a\nb"}})
+	_ = writer.WriteRawEvent("multi\r\nline\r")
+	_ = writer.WriteError(errors.New("boom"))
+	_ = writer.WriteDone()
+
+	events, p := parseAll(t, mock.Body())
+	if len(events) != 4 || p.Retry() != 2*time.Second {
+		t.Fatalf("unexpected events %+v retry %v", events, p.Retry())
+	}
+	v, err := Decode[map[string]string](events[0])
+	if err != nil || v["text"] != "a\nb" || events[0].ID != "7" || events[0].Event != "message" {
+		t.Fatalf("unexpected event %+v %v", events[0], err)
+	}
+	if events[1].Data != "multi\nline\n" {
+		t.Fatalf("unexpected raw data %q", events[1].Data)
+	}
+	if e, _ := Decode[map[string]string](events[2]); events[2].Event != "error" || e["error"] != "boom" {
+		t.Fatalf("unexpected error event %+v", events[2])
+	}
+	if s, _ := Decode[string](events[3]); s != "[DONE]" {
+		t.Fatalf("unexpected done event %+v", events[3])
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	var lastIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		if len(lastIDs) > 2 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		n := len(lastIDs)
+		fmt.Fprintf(w, "retry: 10\n\nid: %d\ndata: {\"n\":%d}\n\n", n, n)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, WithClientRetry(time.Hour))
+	var got []int
+	for e, err := range client.Events(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := Decode[struct{ N int }](e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.N)
+	}
+	if len(got) != 2 || got[1] != 2 {
+		t.Fatalf("unexpected events %v", got)
+	}
+	if strings.Join(lastIDs, ",") != ",1,2" || client.LastEventID() != "2" {
+		t.Fatalf("unexpected Last-Event-ID %q", lastIDs)
+	}
+}
+
+func TestClientInvalidResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	var got error
+	for _, err := range NewClient(srv.URL).Events(context.Background()) {
+		got = err
+	}
+	if !errors.Is(got, ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse, got %v", got)
+	}
+}
@@ -0,0 +1,214 @@
+package sse
+
+import (
+	"compress/gzip"
+	"io"
+	stdhttp "net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// 支持的压缩编码
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// defaultCompressThreshold 默认压缩阈值
+const defaultCompressThreshold = 256
+
+// WithWriterCompression 开启压缩，按 encodings 的顺序与请求头 Accept-Encoding 协商，默认优先 br 其次 gzip
+// 协商成功后由首次写入的大小决定是否压缩（见 WithWriterCompressionThreshold），每个事件写入后刷新压缩器，不会延迟发送；
+// 开启后处理函数返回前需调用 Close，以写出压缩流结尾并归还压缩器
+func WithWriterCompression(encodings ...string) WriterOption {
+	return func(o *writerOptions) {
+		if len(encodings) == 0 {
+			encodings = []string{EncodingBrotli, EncodingGzip}
+		}
+		o.encodings = encodings
+	}
+}
+
+// WithWriterCompressionThreshold 设置压缩阈值，首次写入（通常为首个事件）不足 n 字节时整个流不压缩，默认 256 字节
+// 响应头在首次写入时才发送；n 小于等于 0 时总是压缩
+func WithWriterCompressionThreshold(n int) WriterOption {
+	return func(o *writerOptions) {
+		o.compressThreshold = n
+	}
+}
+
+// negotiateEncoding 按服务端优先顺序选择客户端接受的编码，不支持时返回空
+func negotiateEncoding(acceptEncoding string, encodings []string) string {
+	if acceptEncoding == "" || len(encodings) == 0 {
+		return ""
+	}
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+	for _, enc := range encodings {
+		if ok, exists := accepted[enc]; ok || (!exists && accepted["*"]) {
+			return enc
+		}
+	}
+	return ""
+}
+
+// compressor 可复用的压缩器
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// 压缩级别，动态内容每个事件都要刷新压缩器，使用比默认值更快的级别
+const (
+	gzipLevel   = 4 // gzip 默认为 6
+	brotliLevel = 4 // brotli 默认为 11
+)
+
+// 压缩器池
+var compressorPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		zw, _ := gzip.NewWriterLevel(io.Discard, gzipLevel)
+		return zw
+	}},
+	EncodingBrotli: {New: func() any {
+		return brotli.NewWriterLevel(io.Discard, brotliLevel)
+	}},
+}
+
+// compressWriter 压缩 ResponseWriter，每次刷新时刷新压缩器以保证实时性
+// 首次写入时按大小决定是否压缩，之后写入响应头，未压缩时直接写入底层连接
+type compressWriter struct {
+	mu        sync.Mutex
+	w         stdhttp.ResponseWriter
+	flusher   stdhttp.Flusher
+	encoding  string
+	threshold int
+	code      int
+	decided   bool
+	enc       compressor
+	closed    bool
+	err       error
+}
+
+// newCompressWriter 创建压缩 ResponseWriter，Content-Encoding 与状态码在首次写入时确定
+func newCompressWriter(w stdhttp.ResponseWriter, flusher stdhttp.Flusher, encoding string, threshold int) *compressWriter {
+	return &compressWriter{
+		w:         w,
+		flusher:   flusher,
+		encoding:  encoding,
+		threshold: threshold,
+		code:      stdhttp.StatusOK,
+	}
+}
+
+func (c *compressWriter) Header() stdhttp.Header {
+	return c.w.Header()
+}
+
+// WriteHeader 记录状态码，在首次写入时与 Content-Encoding 一起写出
+func (c *compressWriter) WriteHeader(code int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.decided {
+		c.code = code
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (c *compressWriter) Unwrap() stdhttp.ResponseWriter {
+	return c.w
+}
+
+// start 决定是否压缩并写入响应头，调用方需持有锁
+func (c *compressWriter) start(compress bool) {
+	c.decided = true
+	if compress {
+		c.w.Header().Set("Content-Encoding", c.encoding)
+		c.enc = compressorPools[c.encoding].Get().(compressor)
+		c.enc.Reset(c.w)
+	}
+	c.w.WriteHeader(c.code)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.closed {
+		return 0, ErrWriterClosed
+	}
+	if !c.decided {
+		c.start(len(p) >= c.threshold)
+	}
+	var err error
+	if c.enc != nil {
+		_, err = c.enc.Write(p)
+	} else {
+		_, err = c.w.Write(p)
+	}
+	if err != nil {
+		c.err = err
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush 刷新压缩器与底层连接，首次写入前没有需要发送的内容
+func (c *compressWriter) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.closed || !c.decided {
+		return
+	}
+	if c.enc != nil {
+		if err := c.enc.Flush(); err != nil {
+			c.err = err
+			return
+		}
+	}
+	c.flusher.Flush()
+}
+
+// Close 写出压缩流结尾并归还压缩器
+func (c *compressWriter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return c.err
+	}
+	if !c.decided && c.err == nil {
+		// 没有写入任何内容，以未压缩的空响应结束
+		c.start(false)
+	}
+	c.closed = true
+	if c.enc == nil {
+		if c.err == nil {
+			c.flusher.Flush()
+		}
+		return c.err
+	}
+	if err := c.enc.Close(); err != nil && c.err == nil {
+		c.err = err
+	} else if c.err == nil {
+		c.flusher.Flush()
+	}
+	c.enc.Reset(io.Discard)
+	compressorPools[c.encoding].Put(c.enc)
+	c.enc = nil
+	return c.err
+}
@@ -0,0 +1,149 @@
+package sse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	defaults := []string{EncodingBrotli, EncodingGzip}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip, deflate, br", "br"},
+		{"gzip", "gzip"},
+		{"br;q=0, gzip;q=0.5", "gzip"},
+		{"identity", ""},
+		{"*", "br"},
+		{"*, br;q=0", "gzip"},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.accept, defaults); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func newCompressTestWriter(encoding string, opts ...WriterOption) (*Writer, *mockResponseWriter) {
+	mock := newMockResponseWriter()
+	o := newWriterOptions(opts...)
+	cw := newCompressWriter(mock, mock, encoding, o.compressThreshold)
+	w := &Writer{w: cw, flusher: cw, ctx: context.Background(), compress: cw}
+	w.init(o)
+	return w, mock
+}
+
+func TestCompressGzip(t *testing.T) {
+	writer, mock := newCompressTestWriter(EncodingGzip, WithWriterCompressionThreshold(1))
+	_ = writer.WriteRawEvent("hi")
+
+	if mock.header.Get("Content-Encoding") != "gzip" || mock.statusCode != 200 {
+		t.Fatalf("unexpected headers %v status %d", mock.header, mock.statusCode)
+	}
+	// 首个事件立即发送，每个事件都会刷新压缩器，无需关闭即可解压出已发送的事件
+	zr, err := gzip.NewReader(bytes.NewReader([]byte(mock.Body())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(zr)
+	if string(got) != "data: hi\n\n" {
+		t.Fatalf("unexpected body %q", got)
+	}
+
+	_ = writer.WriteRawEvent("next")
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, _ = gzip.NewReader(bytes.NewReader([]byte(mock.Body())))
+	got, err = io.ReadAll(zr)
+	if err != nil || !strings.HasSuffix(string(got), "data: next\n\n") {
+		t.Fatalf("unexpected body %q err %v", got, err)
+	}
+	if err := writer.WriteRawEvent("closed"); err == nil {
+		t.Fatal("expected error after close")
+	}
+}
+
+func TestCompressBrotli(t *testing.T) {
+	writer, mock := newCompressTestWriter(EncodingBrotli, WithWriterCompressionThreshold(0))
+	for i := 0; i < 3; i++ {
+		_ = writer.WriteEventWithName("chunk", map[string]int{"n": i})
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(brotli.NewReader(strings.NewReader(mock.Body())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, _ := parseAll(t, string(got))
+	if mock.header.Get("Content-Encoding") != "br" || len(events) != 3 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+// 首个事件不足阈值时整个流不压缩，事件不会被延迟
+func TestCompressBelowThreshold(t *testing.T) {
+	writer, mock := newCompressTestWriter(EncodingGzip)
+	_ = writer.WriteRawEvent("tiny")
+	if mock.Body() != "data: tiny\n\n" || mock.header.Get("Content-Encoding") != "" || mock.statusCode != 200 {
+		t.Fatalf("tiny stream should stay uncompressed, got %q %v", mock.Body(), mock.header)
+	}
+	_ = writer.WriteRawEvent(strings.Repeat("token ", 100))
+	if err := writer.Close(); err != nil || !strings.HasPrefix(mock.Body(), "data: tiny\n\ndata: token") {
+		t.Fatalf("unexpected body %q err %v", mock.Body(), err)
+	}
+	if mock.header.Get("Content-Encoding") != "" {
+		t.Fatalf("encoding must not change mid-stream, got %v", mock.header)
+	}
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	srv := khttp.NewServer()
+	srv.HandleFunc("/stream", func(w khttp.ResponseWriter, r *khttp.Request) {
+		writer, _, err := NewWriter(r.Context(), WithWriterCompression(EncodingGzip), WithWriterCompressionThreshold(1))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer writer.Close()
+		_ = writer.WriteRawEvent("hi")
+	})
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	for _, accept := range []string{"identity", "gzip"} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/stream", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body io.Reader = resp.Body
+		if accept == "gzip" {
+			if body, err = gzip.NewReader(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, _ := io.ReadAll(body)
+		resp.Body.Close()
+		// 未压缩的响应也需要 Vary，避免缓存将其返回给支持压缩的客户端
+		if resp.Header.Get("Vary") != "Accept-Encoding" || string(got) != "data: hi\n\n" {
+			t.Fatalf("%s: unexpected response %v %q", accept, resp.Header, got)
+		}
+		if enc := resp.Header.Get("Content-Encoding"); (enc == "gzip") != (accept == "gzip") {
+			t.Fatalf("%s: Content-Encoding = %q", accept, enc)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Receiver 流式数据源，gRPC 服务端流客户端（如 pb.Greeter_StreamClient）均实现该接口
+type Receiver[T any] interface {
+	Recv() (T, error)
+}
+
+// ErrorData 数据源出错时发送的 error 事件数据，gRPC 状态错误按 Kratos 规则映射
+type ErrorData struct {
+	Error    string            `json:"error"`              // 错误信息
+	Code     int               `json:"code"`               // HTTP 状态码
+	Status   string            `json:"status"`             // gRPC 状态码，如 NotFound
+	Reason   string            `json:"reason,omitempty"`   // Kratos 错误原因
+	Metadata map[string]string `json:"metadata,omitempty"` // Kratos 错误元数据
+}
+
+// NewErrorData 将错误转换为 error 事件数据
+func NewErrorData(err error) ErrorData {
+	se := kerrors.FromError(err)
+	return ErrorData{
+		Error:    se.Message,
+		Code:     int(se.Code),
+		Status:   status.Code(se).String(),
+		Reason:   se.Reason,
+		Metadata: se.Metadata,
+	}
+}
+
+type forwardOptions struct {
+	event     string
+	marshal   protojson.MarshalOptions
+	heartbeat time.Duration
+	writer    []WriterOption
+}
+
+// ForwardOption 转发选项
+type ForwardOption func(*forwardOptions)
+
+// WithForwardEvent 设置转发消息的事件名称，默认不设置（即 message 事件）
+func WithForwardEvent(name string) ForwardOption {
+	return func(o *forwardOptions) {
+		o.event = name
+	}
+}
+
+// WithForwardMarshalOptions 设置 protobuf 消息的 JSON 编码选项，默认与 Kratos JSON 编码一致（EmitUnpopulated）
+func WithForwardMarshalOptions(mo protojson.MarshalOptions) ForwardOption {
+	return func(o *forwardOptions) {
+		o.marshal = mo
+	}
+}
+
+// WithForwardHeartbeat 设置心跳间隔，数据源长时间无消息时保持连接，默认不发送
+func WithForwardHeartbeat(d time.Duration) ForwardOption {
+	return func(o *forwardOptions) {
+		o.heartbeat = d
+	}
+}
+
+// WithForwardWriterOptions 设置 ForwardStream 创建 Writer 时的选项
+func WithForwardWriterOptions(opts ...WriterOption) ForwardOption {
+	return func(o *forwardOptions) {
+		o.writer = append(o.writer, opts...)
+	}
+}
+
+func newForwardOptions(opts ...ForwardOption) forwardOptions {
+	o := forwardOptions{
+		marshal: protojson.MarshalOptions{EmitUnpopulated: true},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// encode 编码消息，protobuf 消息使用 protojson
+func (o *forwardOptions) encode(v any) (json.RawMessage, error) {
+	if m, ok := v.(proto.Message); ok {
+		return o.marshal.Marshal(m)
+	}
+	return json.Marshal(v)
+}
+
+// Forward 将流式数据源的每条消息转发为 SSE 事件，直到数据源结束、出错或客户端断开
+// 数据源结束（io.EOF）时发送 [DONE]；出错时发送 error 事件并返回该错误；客户端断开时返回 context 错误
+// 数据源需使用 w.Context() 创建，客户端断开时随之取消，推荐直接使用 ForwardStream
+func Forward[T any](w *Writer, src Receiver[T], opts ...ForwardOption) error {
+	o := newForwardOptions(opts...)
+	return forward(w, src, &o)
+}
+
+// ForwardStream 创建 SSE Writer，以无超时、随客户端断开取消的 context 打开数据源并转发
+//
+//	return nil, sse.ForwardStream(ctx, func(ctx context.Context) (sse.Receiver[*pb.Message], error) {
+//		return s.client.Watch(ctx, req)
+//	})
+func ForwardStream[T any](ctx context.Context, open func(ctx context.Context) (Receiver[T], error), opts ...ForwardOption) error {
+	o := newForwardOptions(opts...)
+	w, streamCtx, err := NewWriter(ctx, o.writer...)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	upstream, cancel := context.WithCancel(streamCtx)
+	defer cancel()
+	src, err := open(upstream)
+	if err != nil {
+		_ = w.WriteFullEvent(Event{Event: "error", Data: NewErrorData(err)})
+		return err
+	}
+	return forward(w, src, &o)
+}
+
+func forward[T any](w *Writer, src Receiver[T], o *forwardOptions) error {
+	if o.heartbeat > 0 {
+		stop := w.StartHeartbeat(o.heartbeat)
+		defer stop()
+	}
+	for {
+		msg, err := src.Recv()
+		if err == io.EOF {
+			return w.WriteDone()
+		}
+		if err != nil {
+			// 客户端断开导致的取消不再写入
+			if ctxErr := w.Context().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if werr := w.WriteFullEvent(Event{Event: "error", Data: NewErrorData(err)}); werr != nil {
+				return errors.Join(err, werr)
+			}
+			return err
+		}
+		data, err := o.encode(msg)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteFullEvent(Event{Event: o.event, Data: data}); err != nil {
+			return err
+		}
+	}
+}
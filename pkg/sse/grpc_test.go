@@ -0,0 +1,113 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// sliceReceiver 依次返回消息，结束后返回 err
+type sliceReceiver[T any] struct {
+	msgs []T
+	err  error
+}
+
+func (r *sliceReceiver[T]) Recv() (T, error) {
+	var zero T
+	if len(r.msgs) == 0 {
+		return zero, r.err
+	}
+	msg := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return msg, nil
+}
+
+func TestForward(t *testing.T) {
+	t.Run("eof", func(t *testing.T) {
+		writer, mock := newTestWriter()
+		src := &sliceReceiver[*wrapperspb.StringValue]{
+			msgs: []*wrapperspb.StringValue{wrapperspb.String("a"), wrapperspb.String("b")},
+			err:  io.EOF,
+		}
+		if err := Forward(writer, Receiver[*wrapperspb.StringValue](src), WithForwardEvent("chunk")); err != nil {
+			t.Fatal(err)
+		}
+		want := "event: chunk\ndata: \"a\"\n\nevent: chunk\ndata: \"b\"\n\ndata: [DONE]\n\n"
+		if mock.Body() != want {
+			t.Fatalf("body = %q, want %q", mock.Body(), want)
+		}
+	})
+
+	t.Run("status error", func(t *testing.T) {
+		writer, mock := newTestWriter()
+		src := &sliceReceiver[map[string]int]{
+			msgs: []map[string]int{{"n": 1}},
+			err:  status.Error(codes.NotFound, "missing"),
+		}
+		err := Forward[map[string]int](writer, src)
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("unexpected err %v", err)
+		}
+		events, _ := parseAll(t, mock.Body())
+		if len(events) != 2 || events[1].Event != "error" {
+			t.Fatalf("unexpected events %+v", events)
+		}
+		data, err := Decode[ErrorData](events[1])
+		if err != nil || data.Code != 404 || data.Status != "NotFound" || data.Error != "missing" {
+			t.Fatalf("unexpected error data %+v %v", data, err)
+		}
+	})
+}
+
+// blockingReceiver 阻塞直到 context 取消，模拟空闲的 gRPC 流
+type blockingReceiver struct {
+	ctx context.Context
+}
+
+func (r blockingReceiver) Recv() (*wrapperspb.StringValue, error) {
+	<-r.ctx.Done()
+	return nil, status.FromContextError(r.ctx.Err()).Err()
+}
+
+func TestForwardStreamCancelsUpstream(t *testing.T) {
+	canceled := make(chan struct{})
+	srv := khttp.NewServer()
+	srv.HandleFunc("/stream", func(w khttp.ResponseWriter, r *khttp.Request) {
+		_ = ForwardStream(r.Context(), func(ctx context.Context) (Receiver[*wrapperspb.StringValue], error) {
+			context.AfterFunc(ctx, func() { close(canceled) })
+			return blockingReceiver{ctx: ctx}, nil
+		}, WithForwardHeartbeat(10*time.Millisecond))
+	})
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewClient(ts.URL+"/stream", WithClientMaxRetries(0))
+	go func() {
+		for range client.Events(ctx) {
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("upstream should be canceled when the client disconnects")
+	}
+}
+
+func TestNewErrorData(t *testing.T) {
+	data := NewErrorData(errors.New("boom"))
+	if data.Error != "boom" || data.Code != 500 || data.Status != "Internal" {
+		t.Fatalf("unexpected error data %+v", data)
+	}
+}
@@ -0,0 +1,351 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 订阅相关错误
+var (
+	ErrHubClosed      = errors.New("sse: hub closed")
+	ErrSlowSubscriber = errors.New("sse: subscriber disconnected for being too slow")
+)
+
+// SlowPolicy 订阅者缓冲区已满时的处理策略
+type SlowPolicy int
+
+const (
+	SlowPolicyDisconnect SlowPolicy = iota // 断开订阅者，客户端重连后可通过重放补发
+	SlowPolicyCoalesce                     // 丢弃最早的未发送事件，保留最新事件
+	SlowPolicyDropEvent                    // 丢弃新事件
+)
+
+type hubOptions struct {
+	buffer    int
+	policy    SlowPolicy
+	store     ReplayStore
+	backplane Backplane
+}
+
+// HubOption Hub 选项
+type HubOption func(*hubOptions)
+
+// WithHubBuffer 设置每个订阅者的缓冲事件数，默认 64
+func WithHubBuffer(n int) HubOption {
+	return func(o *hubOptions) {
+		o.buffer = n
+	}
+}
+
+// WithHubSlowPolicy 设置慢订阅者处理策略，默认 SlowPolicyDisconnect
+func WithHubSlowPolicy(p SlowPolicy) HubOption {
+	return func(o *hubOptions) {
+		o.policy = p
+	}
+}
+
+// WithHubReplayStore 发布时保存事件以分配 ID，订阅单个主题时支持 Last-Event-ID 重放
+func WithHubReplayStore(store ReplayStore) HubOption {
+	return func(o *hubOptions) {
+		o.store = store
+	}
+}
+
+// WithHubBackplane 通过 Backplane 跨实例广播，任一实例发布的事件会推送给所有实例的订阅者
+// 多实例时重放存储也需共享（如 RedisReplayStore），以保证事件 ID 一致
+func WithHubBackplane(bp Backplane) HubOption {
+	return func(o *hubOptions) {
+		o.backplane = bp
+	}
+}
+
+// HubStats Hub 统计信息
+type HubStats struct {
+	Topics       int            `json:"topics"`       // 有订阅者的主题数
+	Subscribers  int            `json:"subscribers"`  // 订阅者数
+	PerTopic     map[string]int `json:"per_topic"`    // 各主题订阅者数
+	Published    uint64         `json:"published"`    // 发布事件数
+	Dropped      uint64         `json:"dropped"`      // 因慢订阅者丢弃的事件数
+	Disconnected uint64         `json:"disconnected"` // 因过慢被断开的订阅者数
+}
+
+// Hub 广播中心，将同一事件推送给订阅了主题的所有连接
+type Hub struct {
+	opts   hubOptions
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+	closed bool
+	cancel context.CancelFunc // 停止接收 Backplane 事件
+	wg     sync.WaitGroup
+
+	published    atomic.Uint64
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
+}
+
+func NewHub(opts ...HubOption) *Hub {
+	o := hubOptions{buffer: 64}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.buffer <= 0 {
+		o.buffer = 1
+	}
+	h := &Hub{
+		opts:   o,
+		topics: make(map[string]map[*Subscription]struct{}),
+	}
+	if o.backplane != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		h.cancel = cancel
+		h.wg.Add(1)
+		go h.receive(ctx)
+	}
+	return h
+}
+
+// receive 接收 Backplane 事件并推送给本实例的订阅者，订阅失败时重试
+func (h *Hub) receive(ctx context.Context) {
+	defer h.wg.Done()
+	for {
+		_ = h.opts.backplane.Subscribe(ctx, h.Broadcast)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Subscription 订阅
+type Subscription struct {
+	hub    *Hub
+	topics []string
+	ch     chan StoredEvent
+	done   chan struct{}
+	once   sync.Once
+	err    atomic.Value
+}
+
+// Events 返回事件 channel，该 channel 不会被关闭，需同时监听 Done
+func (s *Subscription) Events() <-chan StoredEvent {
+	return s.ch
+}
+
+// Done 订阅结束（取消订阅、过慢被断开或 Hub 关闭）时关闭
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err 返回订阅结束的原因
+func (s *Subscription) Err() error {
+	if err, ok := s.err.Load().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.hub.remove(s, context.Canceled)
+}
+
+// Subscribe 订阅一个或多个主题
+func (h *Hub) Subscribe(topics ...string) (*Subscription, error) {
+	s := &Subscription{
+		hub:    h,
+		topics: topics,
+		ch:     make(chan StoredEvent, h.opts.buffer),
+		done:   make(chan struct{}),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	for _, topic := range topics {
+		subs, ok := h.topics[topic]
+		if !ok {
+			subs = make(map[*Subscription]struct{})
+			h.topics[topic] = subs
+		}
+		subs[s] = struct{}{}
+	}
+	return s, nil
+}
+
+// remove 移除订阅
+func (h *Hub) remove(s *Subscription, reason error) {
+	s.once.Do(func() {
+		h.mu.Lock()
+		for _, topic := range s.topics {
+			if subs, ok := h.topics[topic]; ok {
+				delete(subs, s)
+				if len(subs) == 0 {
+					delete(h.topics, topic)
+				}
+			}
+		}
+		h.mu.Unlock()
+		s.err.Store(reason)
+		close(s.done)
+	})
+}
+
+// Publish 发布事件，不阻塞；配置了重放存储时先保存事件并使用存储分配的 ID
+// 配置了 Backplane 时经由 Backplane 推送给所有实例（包括本实例）
+func (h *Hub) Publish(ctx context.Context, topic string, e Event) error {
+	var stored StoredEvent
+	if h.opts.store != nil {
+		var err error
+		if stored, err = h.opts.store.Append(ctx, topic, e.Event, e.Data); err != nil {
+			return err
+		}
+	} else {
+		data, err := encodeEventData(e.Data)
+		if err != nil {
+			return err
+		}
+		stored = StoredEvent{ID: e.ID, Event: e.Event, Data: data}
+	}
+	if h.opts.backplane != nil {
+		return h.opts.backplane.Publish(ctx, topic, stored)
+	}
+	h.Broadcast(topic, stored)
+	return nil
+}
+
+// Broadcast 推送已编码的事件，不保存到重放存储，用于转发其他实例发布的事件
+func (h *Hub) Broadcast(topic string, e StoredEvent) {
+	h.published.Add(1)
+	var slow []*Subscription
+	h.mu.RLock()
+	for s := range h.topics[topic] {
+		if !h.deliver(s, e) {
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+	for _, s := range slow {
+		h.disconnected.Add(1)
+		h.remove(s, ErrSlowSubscriber)
+	}
+}
+
+// deliver 按策略投递事件，返回 false 表示需要断开订阅者
+func (h *Hub) deliver(s *Subscription, e StoredEvent) bool {
+	select {
+	case s.ch <- e:
+		return true
+	default:
+	}
+	switch h.opts.policy {
+	case SlowPolicyCoalesce:
+		select {
+		case <-s.ch:
+			h.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.ch <- e:
+		default:
+			h.dropped.Add(1)
+		}
+		return true
+	case SlowPolicyDropEvent:
+		h.dropped.Add(1)
+		return true
+	default:
+		return false
+	}
+}
+
+// Serve 将 Writer 订阅到主题并持续写入事件，直到客户端断开、订阅被断开或 Hub 关闭
+// 配置了重放存储且只订阅一个主题时，先重放 Last-Event-ID 之后的事件
+func (h *Hub) Serve(w *Writer, topics ...string) error {
+	s, err := h.Subscribe(topics...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var replayed map[string]struct{}
+	if h.opts.store != nil && len(topics) == 1 {
+		replayed, err = w.Replay(w.Context(), h.opts.store, topics[0])
+		if err != nil && !errors.Is(err, ErrReplayGap) {
+			return err
+		}
+	}
+	for {
+		select {
+		case e := <-s.ch:
+			if _, dup := replayed[e.ID]; dup {
+				delete(replayed, e.ID)
+				continue
+			}
+			if err := w.WriteStoredEvent(e); err != nil {
+				return err
+			}
+		case <-s.done:
+			return s.Err()
+		case <-w.Context().Done():
+			return w.Context().Err()
+		}
+	}
+}
+
+// Subscribers 返回主题的订阅者数
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Stats 返回统计信息
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	stats := HubStats{
+		Topics:       len(h.topics),
+		PerTopic:     make(map[string]int, len(h.topics)),
+		Published:    h.published.Load(),
+		Dropped:      h.dropped.Load(),
+		Disconnected: h.disconnected.Load(),
+	}
+	seen := make(map[*Subscription]struct{})
+	for topic, subs := range h.topics {
+		stats.PerTopic[topic] = len(subs)
+		for s := range subs {
+			seen[s] = struct{}{}
+		}
+	}
+	stats.Subscribers = len(seen)
+	return stats
+}
+
+// Close 关闭 Hub，结束所有订阅
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	var subs []*Subscription
+	seen := make(map[*Subscription]struct{})
+	for _, set := range h.topics {
+		for s := range set {
+			if _, ok := seen[s]; !ok {
+				seen[s] = struct{}{}
+				subs = append(subs, s)
+			}
+		}
+	}
+	h.mu.Unlock()
+	for _, s := range subs {
+		h.remove(s, ErrHubClosed)
+	}
+	if h.cancel != nil {
+		h.cancel()
+		h.wg.Wait()
+	}
+}
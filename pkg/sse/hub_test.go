@@ -0,0 +1,112 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHubFanOut(t *testing.T) {
+	hub := NewHub()
+	defer hub.Close()
+	a, _ := hub.Subscribe("news", "alerts")
+	b, _ := hub.Subscribe("news")
+
+	if hub.Subscribers("news") != 2 || hub.Subscribers("alerts") != 1 {
+		t.Fatalf("unexpected subscriber counts: %+v", hub.Stats())
+	}
+	if err := hub.Publish(context.Background(), "news", Event{ID: "1", Data: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*Subscription{a, b} {
+		select {
+		case e := <-s.Events():
+			if e.ID != "1" || string(e.Data) != `"hello"` {
+				t.Fatalf("unexpected event %+v", e)
+			}
+		default:
+			t.Fatal("event not delivered")
+		}
+	}
+
+	a.Close()
+	stats := hub.Stats()
+	if stats.Subscribers != 1 || stats.PerTopic["alerts"] != 0 || stats.Topics != 1 {
+		t.Fatalf("unexpected stats after close: %+v", stats)
+	}
+}
+
+func TestHubSlowPolicies(t *testing.T) {
+	t.Run("disconnect", func(t *testing.T) {
+		hub := NewHub(WithHubBuffer(1))
+		s, _ := hub.Subscribe("t")
+		_ = hub.Publish(context.Background(), "t", Event{Data: 1})
+		_ = hub.Publish(context.Background(), "t", Event{Data: 2})
+		select {
+		case <-s.Done():
+		default:
+			t.Fatal("slow subscriber should be disconnected")
+		}
+		if !errors.Is(s.Err(), ErrSlowSubscriber) || hub.Stats().Disconnected != 1 {
+			t.Fatalf("unexpected err %v stats %+v", s.Err(), hub.Stats())
+		}
+	})
+	t.Run("coalesce", func(t *testing.T) {
+		hub := NewHub(WithHubBuffer(1), WithHubSlowPolicy(SlowPolicyCoalesce))
+		s, _ := hub.Subscribe("t")
+		_ = hub.Publish(context.Background(), "t", Event{Data: 1})
+		_ = hub.Publish(context.Background(), "t", Event{Data: 2})
+		if e := <-s.Events(); string(e.Data) != "2" {
+			t.Fatalf("expected latest event, got %s", e.Data)
+		}
+		if hub.Stats().Dropped != 1 {
+			t.Fatalf("unexpected stats %+v", hub.Stats())
+		}
+	})
+	t.Run("drop", func(t *testing.T) {
+		hub := NewHub(WithHubBuffer(1), WithHubSlowPolicy(SlowPolicyDropEvent))
+		s, _ := hub.Subscribe("t")
+		_ = hub.Publish(context.Background(), "t", Event{Data: 1})
+		_ = hub.Publish(context.Background(), "t", Event{Data: 2})
+		if e := <-s.Events(); string(e.Data) != "1" {
+			t.Fatalf("expected first event, got %s", e.Data)
+		}
+	})
+}
+
+func TestHubServe(t *testing.T) {
+	store := NewMemoryReplayStore(10)
+	hub := NewHub(WithHubReplayStore(store))
+	ctx := context.Background()
+	_ = hub.Publish(ctx, "chat", Event{Event: "message", Data: "m1"})
+	_ = hub.Publish(ctx, "chat", Event{Event: "message", Data: "m2"})
+
+	id := func(seq int) string { return store.streams["chat"].epoch + "-" + strconv.Itoa(seq) }
+	writer, mock := newTestWriter()
+	writer.lastEventID = id(1)
+	done := make(chan error, 1)
+	go func() { done <- hub.Serve(writer, "chat") }()
+
+	deadline := time.Now().Add(time.Second)
+	for hub.Subscribers("chat") == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	_ = hub.Publish(ctx, "chat", Event{Event: "message", Data: "m3"})
+	for !strings.Contains(mock.Body(), "id: "+id(3)) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	hub.Close()
+	if err := <-done; !errors.Is(err, ErrHubClosed) {
+		t.Fatalf("expected ErrHubClosed, got %v", err)
+	}
+	want := "id: " + id(2) + "\nevent: message\ndata: \"m2\"\n\nid: " + id(3) + "\nevent: message\ndata: \"m3\"\n\n"
+	if mock.Body() != want {
+		t.Fatalf("body = %q, want %q", mock.Body(), want)
+	}
+	if _, err := hub.Subscribe("chat"); !errors.Is(err, ErrHubClosed) {
+		t.Fatalf("expected ErrHubClosed, got %v", err)
+	}
+}
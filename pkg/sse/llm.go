@@ -0,0 +1,97 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// ChatDelta 与厂商无关的对话流式增量，用于在 OpenAI 与 Anthropic 流式格式之间转换
+// FinishReason 使用 OpenAI 取值（stop、length、tool_calls、content_filter）
+type ChatDelta struct {
+	ID           string          // 消息 ID（已知时）
+	Model        string          // 模型名称（已知时）
+	Index        int             // 候选项索引（OpenAI choices）
+	Role         string          // 角色，通常只出现在第一个增量
+	Content      string          // 文本增量
+	ToolCalls    []ToolCallDelta // 工具调用增量
+	FinishReason string          // 结束原因
+	Usage        *ChatUsage      // Token 用量
+}
+
+// empty 是否没有任何增量内容
+func (d ChatDelta) empty() bool {
+	return d.Role == "" && d.Content == "" && len(d.ToolCalls) == 0 && d.FinishReason == "" && d.Usage == nil
+}
+
+// ToolCallDelta 工具调用增量，首个增量携带 ID 与 Name，后续增量携带参数片段
+type ToolCallDelta struct {
+	Index     int    // 工具调用序号
+	ID        string // 工具调用 ID
+	Name      string // 函数名称
+	Arguments string // JSON 参数片段
+}
+
+// ChatUsage Token 用量
+type ChatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// LLMError 模型服务返回的错误
+type LLMError struct {
+	Type    string `json:"type"`           // 错误类型，如 invalid_request_error、overloaded_error
+	Code    string `json:"code,omitempty"` // 错误码（OpenAI）
+	Message string `json:"message"`        // 错误信息
+}
+
+func (e *LLMError) Error() string {
+	if e.Type == "" {
+		return "sse: llm error: " + e.Message
+	}
+	return "sse: llm error: " + e.Type + ": " + e.Message
+}
+
+// UnmarshalJSON 兼容 code 为字符串、数字或 null
+func (e *LLMError) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	e.Type, e.Message, e.Code = v.Type, v.Message, ""
+	if code := bytes.TrimSpace(v.Code); len(code) > 0 && !bytes.Equal(code, []byte("null")) {
+		if err := json.Unmarshal(code, &e.Code); err != nil {
+			e.Code = string(code)
+		}
+	}
+	return nil
+}
+
+// 结束原因映射
+var (
+	openAIToAnthropicStop = map[string]string{
+		"stop":           "end_turn",
+		"length":         "max_tokens",
+		"tool_calls":     "tool_use",
+		"content_filter": "refusal",
+	}
+	anthropicToOpenAIStop = map[string]string{
+		"end_turn":      "stop",
+		"stop_sequence": "stop",
+		"max_tokens":    "length",
+		"tool_use":      "tool_calls",
+		"refusal":       "content_filter",
+	}
+)
+
+// mapReason 映射结束原因，未知取值原样返回
+func mapReason(m map[string]string, reason string) string {
+	if v, ok := m[reason]; ok {
+		return v
+	}
+	return reason
+}
@@ -0,0 +1,147 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"iter"
+	"strings"
+	"testing"
+)
+
+// collect 收集增量，合并文本与工具参数便于断言
+func collect(t *testing.T, deltas iter.Seq2[ChatDelta, error]) (content, args, finish string, tools []ToolCallDelta, usage *ChatUsage, err error) {
+	t.Helper()
+	for d, e := range deltas {
+		if e != nil {
+			err = e
+			return
+		}
+		content += d.Content
+		for _, tc := range d.ToolCalls {
+			if tc.ID != "" {
+				tools = append(tools, tc)
+			}
+			args += tc.Arguments
+		}
+		if d.FinishReason != "" {
+			finish = d.FinishReason
+		}
+		if d.Usage != nil {
+			usage = d.Usage
+		}
+	}
+	return
+}
+
+func TestOpenAIStreamRoundTrip(t *testing.T) {
+	writer, mock := newTestWriter()
+	s := NewOpenAIStream(writer, "chatcmpl-1", "gpt-4o")
+	_ = s.WriteRole("assistant")
+	_ = s.WriteContent("Hello")
+	_ = s.WriteContent(" world")
+	_ = s.WriteToolCall(ToolCallDelta{Index: 0, ID: "call_1", Name: "search"})
+	_ = s.WriteToolCall(ToolCallDelta{Index: 0, Arguments: `{"q":`})
+	_ = s.WriteToolCall(ToolCallDelta{Index: 0, Arguments: `"go"}`})
+	_ = s.WriteFinish("tool_calls")
+	_ = s.WriteUsage(ChatUsage{PromptTokens: 10, CompletionTokens: 5})
+	_ = s.Done()
+
+	body := mock.Body()
+	if !strings.Contains(body, `"object":"chat.completion.chunk"`) || !strings.Contains(body, `"finish_reason":null`) ||
+		!strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	content, args, finish, tools, usage, err := collect(t, ParseOpenAI(NewParser(strings.NewReader(body), 0).All()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "Hello world" || args != `{"q":"go"}` || finish != "tool_calls" {
+		t.Fatalf("unexpected content %q args %q finish %q", content, args, finish)
+	}
+	if len(tools) != 1 || tools[0].Name != "search" || usage == nil || usage.TotalTokens != 15 {
+		t.Fatalf("unexpected tools %+v usage %+v", tools, usage)
+	}
+}
+
+func TestAnthropicStreamRoundTrip(t *testing.T) {
+	// OpenAI 增量转写为 Anthropic 事件
+	writer, mock := newTestWriter()
+	s := NewAnthropicStream(writer, "msg_1", "claude")
+	deltas := []ChatDelta{
+		{Role: "assistant"},
+		{Content: "Hi"},
+		{ToolCalls: []ToolCallDelta{{Index: 0, ID: "toolu_1", Name: "search"}}},
+		{ToolCalls: []ToolCallDelta{{Index: 0, Arguments: `{"q":"go"}`}}},
+		{FinishReason: "tool_calls"},
+		{Usage: &ChatUsage{PromptTokens: 10, CompletionTokens: 5}},
+	}
+	for _, d := range deltas {
+		if err := s.WriteDelta(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.Done()
+
+	events, _ := parseAll(t, mock.Body())
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	want := "message_start,content_block_start,content_block_delta,content_block_stop," +
+		"content_block_start,content_block_delta,content_block_stop,message_delta,message_stop"
+	if strings.Join(names, ",") != want {
+		t.Fatalf("events = %v", names)
+	}
+
+	content, args, finish, tools, usage, err := collect(t, ParseAnthropic(NewParser(strings.NewReader(mock.Body()), 0).All()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "Hi" || args != `{"q":"go"}` || finish != "tool_calls" {
+		t.Fatalf("unexpected content %q args %q finish %q", content, args, finish)
+	}
+	if len(tools) != 1 || tools[0].ID != "toolu_1" || tools[0].Index != 0 || usage == nil || usage.CompletionTokens != 5 {
+		t.Fatalf("unexpected tools %+v usage %+v", tools, usage)
+	}
+}
+
+func TestLLMErrors(t *testing.T) {
+	openai := "data: {\"error\":{\"message\":\"quota\",\"type\":\"insufficient_quota\",\"param\":null,\"code\":429}}\n\n"
+	_, _, _, _, _, err := collect(t, ParseOpenAI(NewParser(strings.NewReader(openai), 0).All()))
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) || llmErr.Type != "insufficient_quota" || llmErr.Code != "429" {
+		t.Fatalf("unexpected err %#v", err)
+	}
+
+	writer, mock := newTestWriter()
+	_ = NewAnthropicStream(writer, "msg_1", "claude").WriteError(&LLMError{Type: "overloaded_error", Message: "Overloaded"})
+	_, _, _, _, _, err = collect(t, ParseAnthropic(NewParser(strings.NewReader(mock.Body()), 0).All()))
+	if !errors.As(err, &llmErr) || llmErr.Type != "overloaded_error" || llmErr.Message != "Overloaded" {
+		t.Fatalf("unexpected err %#v", err)
+	}
+
+	var e LLMError
+	if err := json.Unmarshal([]byte(`{"type":"x","message":"m","code":"rate_limit"}`), &e); err != nil || e.Code != "rate_limit" {
+		t.Fatalf("unexpected error %+v %v", e, err)
+	}
+}
+
+// Data 为字符串等非 json.RawMessage 的事件与 ParseOpenAI 一样通过 Decode 解析，无法解析时返回错误
+func TestParseAnthropicDecode(t *testing.T) {
+	events := seqOf[Event](nil,
+		Event{Event: "content_block_start", Data: `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`},
+		Event{Event: "content_block_delta", Data: map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]string{"type": "text_delta", "text": "hi"}}},
+		Event{Event: "message_stop", Data: `{"type":"message_stop"}`},
+	)
+	content, _, _, _, _, err := collect(t, ParseAnthropic(events))
+	if err != nil || content != "hi" {
+		t.Fatalf("content = %q, err = %v", content, err)
+	}
+
+	_, _, _, _, _, err = collect(t, ParseAnthropic(seqOf[Event](nil, Event{Event: "content_block_delta", Data: "not json"})))
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+}
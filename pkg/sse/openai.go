@@ -0,0 +1,214 @@
+package sse
+
+import (
+	"iter"
+	"time"
+)
+
+// OpenAIChunk OpenAI chat.completion.chunk 流式响应块
+type OpenAIChunk struct {
+	ID                string         `json:"id"`
+	Object            string         `json:"object"`
+	Created           int64          `json:"created"`
+	Model             string         `json:"model"`
+	SystemFingerprint string         `json:"system_fingerprint,omitempty"`
+	Choices           []OpenAIChoice `json:"choices"`
+	Usage             *ChatUsage     `json:"usage,omitempty"`
+}
+
+// OpenAIChoice 候选项增量
+type OpenAIChoice struct {
+	Index        int         `json:"index"`
+	Delta        OpenAIDelta `json:"delta"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+// OpenAIDelta 消息增量
+type OpenAIDelta struct {
+	Role      string           `json:"role,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
+}
+
+// OpenAIToolCall 工具调用增量
+type OpenAIToolCall struct {
+	Index    int                `json:"index"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall 函数调用增量
+type OpenAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// OpenAIStream 以 OpenAI chat.completion.chunk 格式写入流式响应
+type OpenAIStream struct {
+	w       *Writer
+	id      string
+	model   string
+	created int64
+}
+
+// NewOpenAIStream 创建 OpenAI 格式的流式响应，id 如 chatcmpl-xxx
+func NewOpenAIStream(w *Writer, id, model string) *OpenAIStream {
+	return &OpenAIStream{
+		w:       w,
+		id:      id,
+		model:   model,
+		created: time.Now().Unix(),
+	}
+}
+
+// WriteChunk 写入响应块，未设置的 id、object、created、model 使用流的默认值
+func (s *OpenAIStream) WriteChunk(c OpenAIChunk) error {
+	if c.ID == "" {
+		c.ID = s.id
+	}
+	if c.Object == "" {
+		c.Object = "chat.completion.chunk"
+	}
+	if c.Created == 0 {
+		c.Created = s.created
+	}
+	if c.Model == "" {
+		c.Model = s.model
+	}
+	if c.Choices == nil {
+		c.Choices = []OpenAIChoice{}
+	}
+	return s.w.WriteEvent(c)
+}
+
+// WriteRole 写入角色，通常为第一个响应块
+func (s *OpenAIStream) WriteRole(role string) error {
+	return s.WriteDelta(ChatDelta{Role: role})
+}
+
+// WriteContent 写入文本增量
+func (s *OpenAIStream) WriteContent(text string) error {
+	return s.WriteDelta(ChatDelta{Content: text})
+}
+
+// WriteToolCall 写入工具调用增量
+func (s *OpenAIStream) WriteToolCall(call ToolCallDelta) error {
+	return s.WriteDelta(ChatDelta{ToolCalls: []ToolCallDelta{call}})
+}
+
+// WriteFinish 写入结束原因，如 stop、length、tool_calls
+func (s *OpenAIStream) WriteFinish(reason string) error {
+	return s.WriteDelta(ChatDelta{FinishReason: reason})
+}
+
+// WriteUsage 写入 Token 用量（choices 为空的响应块，对应 stream_options.include_usage）
+func (s *OpenAIStream) WriteUsage(usage ChatUsage) error {
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return s.WriteChunk(OpenAIChunk{Usage: &usage})
+}
+
+// WriteDelta 写入增量，Usage 单独写入一个响应块
+func (s *OpenAIStream) WriteDelta(d ChatDelta) error {
+	usage := d.Usage
+	d.Usage = nil
+	if !d.empty() {
+		choice := OpenAIChoice{
+			Index: d.Index,
+			Delta: OpenAIDelta{Role: d.Role, Content: d.Content},
+		}
+		for _, call := range d.ToolCalls {
+			tc := OpenAIToolCall{
+				Index:    call.Index,
+				ID:       call.ID,
+				Function: OpenAIFunctionCall{Name: call.Name, Arguments: call.Arguments},
+			}
+			if call.ID != "" {
+				tc.Type = "function"
+			}
+			choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, tc)
+		}
+		if d.FinishReason != "" {
+			choice.FinishReason = &d.FinishReason
+		}
+		if err := s.WriteChunk(OpenAIChunk{Choices: []OpenAIChoice{choice}}); err != nil {
+			return err
+		}
+	}
+	if usage != nil {
+		return s.WriteUsage(*usage)
+	}
+	return nil
+}
+
+// WriteError 写入 OpenAI 格式的错误对象
+func (s *OpenAIStream) WriteError(e *LLMError) error {
+	return s.w.WriteEvent(map[string]*LLMError{"error": e})
+}
+
+// Done 写入结束标记 [DONE]
+func (s *OpenAIStream) Done() error {
+	return s.w.WriteDone()
+}
+
+// openAIMessage OpenAI 流式响应块或错误对象
+type openAIMessage struct {
+	OpenAIChunk
+	Error *LLMError `json:"error"`
+}
+
+// ParseOpenAI 将 OpenAI 流式响应解析为增量，收到 [DONE] 时结束；收到错误对象时返回 *LLMError
+//
+//	for d, err := range sse.ParseOpenAI(client.Events(ctx)) { ... }
+func ParseOpenAI(events iter.Seq2[Event, error]) iter.Seq2[ChatDelta, error] {
+	return func(yield func(ChatDelta, error) bool) {
+		for e, err := range events {
+			if err != nil {
+				yield(ChatDelta{}, err)
+				return
+			}
+			if e.Data == "[DONE]" {
+				return
+			}
+			msg, err := Decode[openAIMessage](e)
+			if err != nil {
+				yield(ChatDelta{}, err)
+				return
+			}
+			if msg.Error != nil {
+				yield(ChatDelta{}, msg.Error)
+				return
+			}
+			for _, choice := range msg.Choices {
+				d := ChatDelta{
+					ID:      msg.ID,
+					Model:   msg.Model,
+					Index:   choice.Index,
+					Role:    choice.Delta.Role,
+					Content: choice.Delta.Content,
+				}
+				for _, tc := range choice.Delta.ToolCalls {
+					d.ToolCalls = append(d.ToolCalls, ToolCallDelta{
+						Index:     tc.Index,
+						ID:        tc.ID,
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					})
+				}
+				if choice.FinishReason != nil {
+					d.FinishReason = *choice.FinishReason
+				}
+				if !d.empty() && !yield(d, nil) {
+					return
+				}
+			}
+			if msg.Usage != nil {
+				if !yield(ChatDelta{ID: msg.ID, Model: msg.Model, Usage: msg.Usage}, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package sse
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReplayGap Last-Event-ID 之后的部分事件已被淘汰，只能重放仍保留的事件
+var ErrReplayGap = errors.New("sse: some events after Last-Event-ID have been evicted")
+
+// StoredEvent 已保存的事件，Data 为编码后的 JSON
+type StoredEvent struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event,omitempty"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// ReplayStore 事件重放存储，按流（stream）保存最近的事件并分配递增的事件 ID
+type ReplayStore interface {
+	// Append 保存事件并返回分配了 ID 的事件
+	Append(ctx context.Context, stream string, event string, data any) (StoredEvent, error)
+	// Since 返回 lastID 之后的事件，lastID 为空时返回空
+	// lastID 之后的部分事件已被淘汰时，返回保留的事件与 ErrReplayGap
+	Since(ctx context.Context, stream, lastID string) ([]StoredEvent, error)
+}
+
+// encodeEventData 编码事件数据，与 WriteEvent 的编码规则一致
+func encodeEventData(data any) (json.RawMessage, error) {
+	if raw, ok := data.(json.RawMessage); ok {
+		return raw, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal data failed: %w", err)
+	}
+	return b, nil
+}
+
+// memoryStream 单个流的环形缓冲
+type memoryStream struct {
+	name   string
+	epoch  string // 流创建时分配的纪元，作为事件 ID 前缀
+	events []StoredEvent
+	seqs   []uint64
+	next   int       // 下一个写入位置
+	count  int       // 已保存的事件数
+	seq    uint64    // 最近分配的事件序号
+	active time.Time // 最近写入时间
+	elem   *list.Element
+}
+
+// MemoryReplayOption 内存重放存储选项
+type MemoryReplayOption func(*MemoryReplayStore)
+
+// WithMemoryReplayTTL 设置流无新事件后的保留时间，默认 1 小时
+func WithMemoryReplayTTL(d time.Duration) MemoryReplayOption {
+	return func(m *MemoryReplayStore) {
+		m.ttl = d
+	}
+}
+
+// WithMemoryReplayMaxStreams 设置最多保留的流数量，超出时淘汰最久没有新事件的流，默认 10000
+func WithMemoryReplayMaxStreams(n int) MemoryReplayOption {
+	return func(m *MemoryReplayStore) {
+		m.maxStreams = n
+	}
+}
+
+// MemoryReplayStore 内存重放存储，每个流保留最近 size 条事件，仅适用于单实例
+// 事件 ID 格式为 "<纪元>-<序号>"，流被淘汰或进程重启后纪元变化，旧 ID 重连时返回 ErrReplayGap
+type MemoryReplayStore struct {
+	mu         sync.Mutex
+	size       int
+	ttl        time.Duration
+	maxStreams int
+	epoch      int64
+	streams    map[string]*memoryStream
+	lru        *list.List // 按最近写入时间排序，最久未写入的在队尾
+}
+
+var _ ReplayStore = (*MemoryReplayStore)(nil)
+
+// NewMemoryReplayStore 创建内存重放存储，size 为每个流保留的事件数，默认 1000
+func NewMemoryReplayStore(size int, opts ...MemoryReplayOption) *MemoryReplayStore {
+	if size <= 0 {
+		size = 1000
+	}
+	m := &MemoryReplayStore{
+		size:       size,
+		ttl:        time.Hour,
+		maxStreams: 10000,
+		streams:    make(map[string]*memoryStream),
+		lru:        list.New(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Append 保存事件
+func (m *MemoryReplayStore) Append(ctx context.Context, stream string, event string, data any) (StoredEvent, error) {
+	raw, err := encodeEventData(data)
+	if err != nil {
+		return StoredEvent{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.evict(now)
+	s, ok := m.streams[stream]
+	if !ok {
+		s = m.create(stream, now)
+	}
+	s.active = now
+	m.lru.MoveToFront(s.elem)
+	s.seq++
+	e := StoredEvent{ID: s.epoch + "-" + strconv.FormatUint(s.seq, 10), Event: event, Data: raw}
+	s.events[s.next] = e
+	s.seqs[s.next] = s.seq
+	s.next = (s.next + 1) % m.size
+	s.count = min(s.count+1, m.size)
+	return e, nil
+}
+
+// create 创建流，超出数量上限时淘汰最久没有新事件的流
+func (m *MemoryReplayStore) create(stream string, now time.Time) *memoryStream {
+	if m.maxStreams > 0 {
+		for len(m.streams) >= m.maxStreams {
+			m.remove(m.lru.Back().Value.(*memoryStream))
+		}
+	}
+	// 纪元单调递增，同一毫秒内重建的流也不会复用旧 ID
+	m.epoch = max(now.UnixMilli(), m.epoch+1)
+	s := &memoryStream{
+		name:   stream,
+		epoch:  strconv.FormatInt(m.epoch, 36),
+		events: make([]StoredEvent, m.size),
+		seqs:   make([]uint64, m.size),
+	}
+	s.elem = m.lru.PushFront(s)
+	m.streams[stream] = s
+	return s
+}
+
+// evict 淘汰超过保留时间没有新事件的流
+func (m *MemoryReplayStore) evict(now time.Time) {
+	if m.ttl <= 0 {
+		return
+	}
+	for e := m.lru.Back(); e != nil; e = m.lru.Back() {
+		s := e.Value.(*memoryStream)
+		if now.Sub(s.active) < m.ttl {
+			return
+		}
+		m.remove(s)
+	}
+}
+
+func (m *MemoryReplayStore) remove(s *memoryStream) {
+	m.lru.Remove(s.elem)
+	delete(m.streams, s.name)
+}
+
+// Since 返回 lastID 之后的事件
+// lastID 无法识别、属于已淘汰的流或晚于最新事件时，返回保留的全部事件与 ErrReplayGap
+func (m *MemoryReplayStore) Since(ctx context.Context, stream, lastID string) ([]StoredEvent, error) {
+	if lastID == "" {
+		return nil, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.evict(time.Now())
+	s, ok := m.streams[stream]
+	if !ok || s.count == 0 {
+		// 流已被淘汰或尚未产生事件，客户端收到过的事件来自此前的流
+		return nil, ErrReplayGap
+	}
+	oldest := (s.next - s.count + m.size) % m.size
+	epoch, seq, _ := strings.Cut(lastID, "-")
+	last, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || epoch != s.epoch || last > s.seq {
+		last = 0
+		err = ErrReplayGap
+	}
+	var res []StoredEvent
+	for i := 0; i < s.count; i++ {
+		idx := (oldest + i) % m.size
+		if s.seqs[idx] > last {
+			res = append(res, s.events[idx])
+		}
+	}
+	if err != nil {
+		return res, err
+	}
+	// 最早保留的事件之前还有未发送的事件
+	if s.seqs[oldest] > last+1 {
+		return res, ErrReplayGap
+	}
+	return res, nil
+}
+
+// Remove 删除流的全部事件
+func (m *MemoryReplayStore) Remove(stream string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.streams[stream]; ok {
+		m.remove(s)
+	}
+}
+
+// LastEventID 返回客户端重连时携带的 Last-Event-ID，首次连接为空
+func (s *Writer) LastEventID() string {
+	return s.lastEventID
+}
+
+// WriteStoredEvent 写入已保存的事件
+func (s *Writer) WriteStoredEvent(e StoredEvent) error {
+	return s.WriteFullEvent(Event{ID: e.ID, Event: e.Event, Data: e.Data})
+}
+
+// Replay 重放 Last-Event-ID 之后的事件，返回已重放的事件 ID
+// 部分事件已被淘汰时仍会重放保留的事件，并返回 ErrReplayGap
+func (s *Writer) Replay(ctx context.Context, store ReplayStore, stream string) (map[string]struct{}, error) {
+	events, err := store.Since(ctx, stream, s.lastEventID)
+	if err != nil && !errors.Is(err, ErrReplayGap) {
+		return nil, err
+	}
+	replayed := make(map[string]struct{}, len(events))
+	for _, e := range events {
+		if werr := s.WriteStoredEvent(e); werr != nil {
+			return replayed, werr
+		}
+		replayed[e.ID] = struct{}{}
+	}
+	return replayed, err
+}
+
+// Resume 先重放错过的事件，再转发实时事件，直到 live 关闭或客户端断开
+// live 需在调用前订阅，与重放重叠的事件会按 ID 去重；事件被淘汰（ErrReplayGap）时继续转发实时事件
+func (s *Writer) Resume(ctx context.Context, store ReplayStore, stream string, live <-chan StoredEvent) error {
+	replayed, err := s.Replay(ctx, store, stream)
+	if err != nil && !errors.Is(err, ErrReplayGap) {
+		return err
+	}
+	for {
+		select {
+		case e, ok := <-live:
+			if !ok {
+				return nil
+			}
+			if _, dup := replayed[e.ID]; dup {
+				delete(replayed, e.ID)
+				continue
+			}
+			if err := s.WriteStoredEvent(e); err != nil {
+				return err
+			}
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisReplayStore 基于 Redis Stream 的重放存储，多实例共享，事件 ID 为 Stream 条目 ID
+type RedisReplayStore struct {
+	client redis.UniversalClient
+	prefix string
+	size   int64
+	ttl    time.Duration
+}
+
+var _ ReplayStore = (*RedisReplayStore)(nil)
+
+// NewRedisReplayStore 创建 Redis 重放存储
+// prefix 为空时使用 "sse:replay:"；size 为每个流大约保留的事件数，默认 1000；
+// ttl 为流无新事件后的保留时间，默认 1 小时
+func NewRedisReplayStore(client redis.UniversalClient, prefix string, size int64, ttl time.Duration) *RedisReplayStore {
+	if prefix == "" {
+		prefix = "sse:replay:"
+	}
+	if size <= 0 {
+		size = 1000
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &RedisReplayStore{
+		client: client,
+		prefix: prefix,
+		size:   size,
+		ttl:    ttl,
+	}
+}
+
+// Append 保存事件
+func (r *RedisReplayStore) Append(ctx context.Context, stream string, event string, data any) (StoredEvent, error) {
+	raw, err := encodeEventData(data)
+	if err != nil {
+		return StoredEvent{}, err
+	}
+	key := r.prefix + stream
+	pipe := r.client.TxPipeline()
+	add := pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: r.size,
+		Approx: true,
+		Values: map[string]any{"event": event, "data": string(raw)},
+	})
+	pipe.Expire(ctx, key, r.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return StoredEvent{}, err
+	}
+	return StoredEvent{ID: add.Val(), Event: event, Data: raw}, nil
+}
+
+// Since 返回 lastID 之后的事件，lastID 无法识别时返回保留的全部事件与 ErrReplayGap
+// 流已过期或被删除时返回 ErrReplayGap，与 MemoryReplayStore 一致
+func (r *RedisReplayStore) Since(ctx context.Context, stream, lastID string) ([]StoredEvent, error) {
+	if lastID == "" {
+		return nil, nil
+	}
+	key := r.prefix + stream
+	last, err := parseStreamID(lastID)
+	if err != nil {
+		// 无法识别的 ID 不是本存储分配的，重放保留的全部事件
+		res, err := r.xrange(ctx, key, "-")
+		if err != nil {
+			return nil, err
+		}
+		return res, ErrReplayGap
+	}
+	res, err := r.xrange(ctx, key, "("+lastID)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		// 流已过期，客户端收到过的事件来自此前的流
+		n, err := r.client.Exists(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrReplayGap
+		}
+	}
+	// 被淘汰的最大条目 ID 晚于 lastID 时说明有事件丢失（需要 Redis 7.0+，低版本不检测）
+	info, err := r.client.XInfoStream(ctx, key).Result()
+	if err != nil || info.MaxDeletedEntryID == "" {
+		return res, nil
+	}
+	if deleted, err := parseStreamID(info.MaxDeletedEntryID); err == nil && deleted.after(last) {
+		return res, ErrReplayGap
+	}
+	return res, nil
+}
+
+// xrange 返回 start 之后保留的事件
+func (r *RedisReplayStore) xrange(ctx context.Context, key, start string) ([]StoredEvent, error) {
+	msgs, err := r.client.XRange(ctx, key, start, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]StoredEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		e := StoredEvent{ID: msg.ID}
+		e.Event, _ = msg.Values["event"].(string)
+		data, _ := msg.Values["data"].(string)
+		e.Data = []byte(data)
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// Remove 删除流的全部事件
+func (r *RedisReplayStore) Remove(ctx context.Context, stream string) error {
+	return r.client.Del(ctx, r.prefix+stream).Err()
+}
+
+// streamID Redis Stream 条目 ID
+type streamID struct {
+	ms, seq uint64
+}
+
+func parseStreamID(id string) (streamID, error) {
+	msPart, seqPart, ok := strings.Cut(id, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return streamID{}, fmt.Errorf("sse: invalid Last-Event-ID %q", id)
+	}
+	var seq uint64
+	if ok {
+		if seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return streamID{}, fmt.Errorf("sse: invalid Last-Event-ID %q", id)
+		}
+	}
+	return streamID{ms: ms, seq: seq}, nil
+}
+
+func (s streamID) after(o streamID) bool {
+	return s.ms > o.ms || (s.ms == o.ms && s.seq > o.seq)
+}
@@ -0,0 +1,191 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryReplayStore(t *testing.T) {
+	store := NewMemoryReplayStore(3)
+	ctx := context.Background()
+	var ids []string
+	for i := 1; i <= 5; i++ {
+		e, err := store.Append(ctx, "chat:1", "message", map[string]int{"n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.ID == "" {
+			t.Fatal("event id should be assigned")
+		}
+		ids = append(ids, e.ID)
+	}
+
+	events, err := store.Since(ctx, "chat:1", ids[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ID != ids[3] || string(events[1].Data) != `{"n":5}` {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	// 事件 2 已被淘汰
+	events, err = store.Since(ctx, "chat:1", ids[0])
+	if !errors.Is(err, ErrReplayGap) || len(events) != 3 {
+		t.Fatalf("expected gap with 3 events, got %d %v", len(events), err)
+	}
+
+	if events, err := store.Since(ctx, "chat:1", ""); err != nil || len(events) != 0 {
+		t.Fatalf("first connection should not replay, got %v %v", events, err)
+	}
+	// 无法识别的 ID 重放保留的全部事件
+	if events, err := store.Since(ctx, "chat:1", "abc"); !errors.Is(err, ErrReplayGap) || len(events) != 3 {
+		t.Fatalf("expected gap with 3 events for invalid id, got %d %v", len(events), err)
+	}
+}
+
+func TestMemoryReplayStoreEpoch(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryReplayStore(10)
+	var old []string
+	for i := 1; i <= 3; i++ {
+		e, _ := store.Append(ctx, "chat:1", "", i)
+		old = append(old, e.ID)
+	}
+
+	// 流被删除后重建，序号从头开始，旧 ID 不能与新事件混淆
+	store.Remove("chat:1")
+	if events, err := store.Since(ctx, "chat:1", old[2]); !errors.Is(err, ErrReplayGap) || len(events) != 0 {
+		t.Fatalf("removed stream should report gap, got %v %v", events, err)
+	}
+	for i := 1; i <= 5; i++ {
+		e, _ := store.Append(ctx, "chat:1", "", i)
+		if slices.Contains(old, e.ID) {
+			t.Fatalf("recreated stream reused id %q", e.ID)
+		}
+	}
+	events, err := store.Since(ctx, "chat:1", old[1])
+	if !errors.Is(err, ErrReplayGap) || len(events) != 5 {
+		t.Fatalf("expected gap with 5 events, got %d %v", len(events), err)
+	}
+
+	// 同一纪元中晚于最新事件的 ID
+	latest := events[4].ID
+	epoch, _, _ := strings.Cut(latest, "-")
+	if events, err := store.Since(ctx, "chat:1", epoch+"-9"); !errors.Is(err, ErrReplayGap) || len(events) != 5 {
+		t.Fatalf("expected gap with 5 events for future id, got %d %v", len(events), err)
+	}
+	if events, err := store.Since(ctx, "chat:1", latest); err != nil || len(events) != 0 {
+		t.Fatalf("expected no events after latest, got %v %v", events, err)
+	}
+}
+
+func TestMemoryReplayStoreEviction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ttl", func(t *testing.T) {
+		store := NewMemoryReplayStore(10, WithMemoryReplayTTL(20*time.Millisecond))
+		e, _ := store.Append(ctx, "idle", "", 1)
+		time.Sleep(30 * time.Millisecond)
+		_, _ = store.Append(ctx, "active", "", 1)
+		if _, ok := store.streams["idle"]; ok {
+			t.Fatal("idle stream should be evicted")
+		}
+		if _, err := store.Since(ctx, "idle", e.ID); !errors.Is(err, ErrReplayGap) {
+			t.Fatalf("expected gap for evicted stream, got %v", err)
+		}
+	})
+
+	t.Run("max streams", func(t *testing.T) {
+		store := NewMemoryReplayStore(10, WithMemoryReplayMaxStreams(2))
+		_, _ = store.Append(ctx, "a", "", 1)
+		_, _ = store.Append(ctx, "b", "", 1)
+		_, _ = store.Append(ctx, "a", "", 2)
+		_, _ = store.Append(ctx, "c", "", 1)
+		if len(store.streams) != 2 || store.streams["b"] != nil {
+			t.Fatalf("least recently written stream should be evicted, got %v", store.streams)
+		}
+	})
+}
+
+func TestRedisReplayStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	store := NewRedisReplayStore(rdb, "", 10, time.Minute)
+	ctx := context.Background()
+	var ids []string
+	for i := 1; i <= 3; i++ {
+		e, err := store.Append(ctx, "chat:1", "message", i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, e.ID)
+	}
+
+	events, err := store.Since(ctx, "chat:1", ids[0])
+	if err != nil || len(events) != 2 || events[0].ID != ids[1] {
+		t.Fatalf("unexpected events %+v %v", events, err)
+	}
+	if events, err := store.Since(ctx, "chat:1", "abc"); !errors.Is(err, ErrReplayGap) || len(events) != 3 {
+		t.Fatalf("expected gap with 3 events for invalid id, got %d %v", len(events), err)
+	}
+	if events, err := store.Since(ctx, "chat:1", ids[2]); err != nil || len(events) != 0 {
+		t.Fatalf("expected no events without gap for the latest id, got %d %v", len(events), err)
+	}
+
+	// 流过期后重连的客户端收到 ErrReplayGap
+	mr.FastForward(2 * time.Minute)
+	if events, err := store.Since(ctx, "chat:1", ids[2]); !errors.Is(err, ErrReplayGap) || len(events) != 0 {
+		t.Fatalf("expected gap for expired stream, got %d %v", len(events), err)
+	}
+}
+
+func TestLastEventID(t *testing.T) {
+	r := httptest.NewRequest("GET", "/stream?lastEventId=7", nil)
+	if got := lastEventID(r); got != "7" {
+		t.Fatalf("lastEventID = %q", got)
+	}
+	r.Header.Set("Last-Event-ID", "9")
+	if got := lastEventID(r); got != "9" {
+		t.Fatalf("lastEventID = %q", got)
+	}
+}
+
+func TestResume(t *testing.T) {
+	store := NewMemoryReplayStore(10)
+	ctx := context.Background()
+	var ids []string
+	for i := 1; i <= 3; i++ {
+		e, _ := store.Append(ctx, "chat:1", "", i)
+		ids = append(ids, e.ID)
+	}
+	writer, mock := newTestWriter()
+	writer.lastEventID = ids[0]
+
+	live := make(chan StoredEvent, 2)
+	// 订阅后、重放前发布的事件会同时出现在重放与实时事件中
+	live <- StoredEvent{ID: ids[2], Data: []byte("3")}
+	e4, _ := store.Append(ctx, "chat:1", "", 4)
+	live <- e4
+	close(live)
+
+	if err := writer.Resume(ctx, store, "chat:1", live); err != nil {
+		t.Fatal(err)
+	}
+	body := mock.Body()
+	if strings.Count(body, "id: "+ids[2]+"\n") != 1 {
+		t.Fatalf("event 3 should be written once, got %q", body)
+	}
+	want := "id: " + ids[1] + "\ndata: 2\n\nid: " + ids[2] + "\ndata: 3\n\nid: " + e4.ID + "\ndata: 4\n\n"
+	if body != want {
+		t.Fatalf("body = %q, want %q", body, want)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kratos/kratos/v2/transport"
@@ -68,25 +69,84 @@ func putBuffer(buf *bytes.Buffer) {
 
 // Writer SSE 流式写入器
 type Writer struct {
-	mu      sync.Mutex
-	w       stdhttp.ResponseWriter
-	flusher stdhttp.Flusher
-	ctx     context.Context
+	mu          sync.Mutex
+	w           stdhttp.ResponseWriter
+	flusher     stdhttp.Flusher
+	ctx         context.Context
+	lastEventID string // 客户端重连时携带的 Last-Event-ID
+
+	rc           *stdhttp.ResponseController
+	writeTimeout time.Duration // 单次写入超时
+	cancel       func(error)   // 写入失败时结束流式 context
+	queue        *writeQueue   // 异步模式下的写入队列
+	compress     *compressWriter
+	written      atomic.Uint64
+	dropped      atomic.Uint64
 }
 
 // streamContext 创建一个完全独立的 context，不受 Kratos 超时中间件影响
 // 不嵌入原始 context，避免 SDK 检测到超时
-// 通过监听底层连接（CloseNotifier）检测客户端断开
+// 通过监听请求 context 检测客户端断开，超时（DeadlineExceeded）不视为断开
 type streamContext struct {
 	values    context.Context // 仅用于传递 Value，不作为父 context
 	done      chan struct{}   // 客户端断开信号
 	closeOnce sync.Once       // 确保只关闭一次
-	errMu     sync.RWMutex    // 保护 err 字段
+	errMu     sync.RWMutex    // 保护 err、timer 字段
 	err       error           // 存储错误
+	deadline  time.Time       // 最大流式时长，零值表示不限制
+	timer     *time.Timer
+}
+
+// rawContextKey 保存未被 Kratos 超时控制的原始请求 context
+type rawContextKey struct{}
+
+// Filter 保存原始请求 context，使 Kratos 超时触发后仍能检测客户端断开
+// 通过 http.Filter(sse.Filter()) 注册；未注册时超时触发后只能通过写入失败检测断开
+func Filter() http.FilterFunc {
+	return func(next stdhttp.Handler) stdhttp.Handler {
+		return stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			ctx := r.Context()
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, rawContextKey{}, ctx)))
+		})
+	}
+}
+
+// newStreamContext 创建流式 context，监听请求 context 检测客户端断开
+// 请求结束或客户端断开时请求 context 被取消；lifetime 大于 0 时到期后以 DeadlineExceeded 结束
+func newStreamContext(ctx context.Context, lifetime time.Duration) *streamContext {
+	sc := &streamContext{
+		values: ctx, // 仅用于 Value() 方法传递（如 transport 信息）
+		done:   make(chan struct{}),
+	}
+	if lifetime > 0 {
+		sc.deadline = time.Now().Add(lifetime)
+		sc.errMu.Lock()
+		sc.timer = time.AfterFunc(lifetime, func() {
+			sc.cancel(context.DeadlineExceeded)
+		})
+		sc.errMu.Unlock()
+	}
+
+	watch := ctx
+	if raw, ok := ctx.Value(rawContextKey{}).(context.Context); ok {
+		watch = raw
+	}
+	go func() {
+		select {
+		case <-watch.Done():
+			// 超时中间件触发的 DeadlineExceeded 不代表客户端断开
+			if !errors.Is(watch.Err(), context.DeadlineExceeded) {
+				sc.cancel(context.Canceled)
+			}
+		case <-sc.done:
+			// 已经被其他方式关闭
+		}
+	}()
+	return sc
 }
 
 func (c *streamContext) Deadline() (time.Time, bool) {
-	return time.Time{}, false // 永不超时
+	return c.deadline, !c.deadline.IsZero() // 仅受最大流式时长限制
 }
 
 func (c *streamContext) Done() <-chan struct{} {
@@ -108,14 +168,33 @@ func (c *streamContext) cancel(err error) {
 	c.closeOnce.Do(func() {
 		c.errMu.Lock()
 		c.err = err
+		timer := c.timer
 		c.errMu.Unlock()
 		close(c.done)
+		if timer != nil {
+			timer.Stop()
+		}
 	})
 }
 
+// findFlusher 查找 Flusher，兼容通过 Unwrap 暴露底层 ResponseWriter 的包装（与 http.ResponseController 一致）
+func findFlusher(w stdhttp.ResponseWriter) (stdhttp.Flusher, bool) {
+	for {
+		if f, ok := w.(stdhttp.Flusher); ok {
+			return f, true
+		}
+		u, ok := w.(interface{ Unwrap() stdhttp.ResponseWriter })
+		if !ok {
+			return nil, false
+		}
+		w = u.Unwrap()
+	}
+}
+
 // NewWriter 从 Kratos context 中创建 SSE Writer
 // 返回 Writer 和一个无超时的 context（用于长时间流式操作）
-func NewWriter(ctx context.Context) (*Writer, context.Context, error) {
+// 默认同步写入；通过 WithWriterBuffer 开启异步模式时，处理函数返回前需调用 Close
+func NewWriter(ctx context.Context, opts ...WriterOption) (*Writer, context.Context, error) {
 	// 使用 transport.FromServerContext 获取传输层信息
 	tr, ok := transport.FromServerContext(ctx)
 	if !ok {
@@ -137,7 +216,7 @@ func NewWriter(ctx context.Context) (*Writer, context.Context, error) {
 	w := httpTransport.Response()
 
 	// 先检查 Flusher 支持，避免写入响应头后才发现不支持流式
-	flusher, ok := w.(stdhttp.Flusher)
+	flusher, ok := findFlusher(w)
 	if !ok {
 		return nil, nil, ErrStreamingNotSupported
 	}
@@ -149,34 +228,51 @@ func NewWriter(ctx context.Context) (*Writer, context.Context, error) {
 	header.Set("Connection", "keep-alive")
 	header.Set("X-Accel-Buffering", "no") // 禁用 Nginx 缓存，确保流式实时性
 
-	// 写入 200 状态码（在确认支持流式后再写）
-	w.WriteHeader(stdhttp.StatusOK)
+	// 协商压缩编码，开启压缩时无论是否协商成功都添加 Vary，避免缓存将压缩与未压缩的响应混用
+	o := newWriterOptions(opts...)
+	var cw *compressWriter
+	if len(o.encodings) > 0 {
+		header.Add("Vary", "Accept-Encoding")
+		if enc := negotiateEncoding(httpTransport.RequestHeader().Get("Accept-Encoding"), o.encodings); enc != "" && header.Get("Content-Encoding") == "" {
+			cw = newCompressWriter(w, flusher, enc, o.compressThreshold)
+		}
+	}
+
+	// 写入 200 状态码（在确认支持流式后再写），压缩时与 Content-Encoding 一起在首次写入时写出
+	if cw != nil {
+		cw.WriteHeader(stdhttp.StatusOK)
+	} else {
+		w.WriteHeader(stdhttp.StatusOK)
+	}
 
 	// 关键：创建完全独立的 context，脱离 Kratos 超时中间件控制
 	// 不嵌入原始 context（避免 SDK 内部检测到超时），仅保留 Value 传递能力
-	// 通过 http.CloseNotifier（已废弃但仍可用）或写入失败来检测客户端断开
-	streamCtx := &streamContext{
-		values: ctx, // 仅用于 Value() 方法传递（如 transport 信息）
-		done:   make(chan struct{}),
+	// 通过请求 context 或写入失败来检测客户端断开
+	streamCtx := newStreamContext(ctx, o.maxLifetime)
+
+	writer := &Writer{
+		w:           w,
+		flusher:     flusher,
+		ctx:         streamCtx,
+		lastEventID: lastEventID(httpTransport.Request()),
+		cancel:      streamCtx.cancel,
 	}
-
-	// 尝试使用 CloseNotifier 检测客户端断开（Go 1.11+ http.Server 仍支持）
-	if cn, ok := w.(stdhttp.CloseNotifier); ok {
-		go func() {
-			select {
-			case <-cn.CloseNotify():
-				streamCtx.cancel(context.Canceled)
-			case <-streamCtx.done:
-				// 已经被其他方式关闭
-			}
-		}()
+	if cw != nil {
+		writer.w, writer.flusher, writer.compress = cw, cw, cw
 	}
+	writer.init(o)
+	return writer, streamCtx, nil
+}
 
-	return &Writer{
-		w:       w,
-		flusher: flusher,
-		ctx:     streamCtx,
-	}, streamCtx, nil
+// lastEventID 读取请求头 Last-Event-ID，兼容不支持自定义请求头的客户端通过查询参数 lastEventId 传递
+func lastEventID(r *stdhttp.Request) string {
+	if r == nil {
+		return ""
+	}
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("lastEventId")
 }
 
 // Context 返回无超时的流式 context
@@ -184,28 +280,40 @@ func (s *Writer) Context() context.Context {
 	return s.ctx
 }
 
+// send 发送一个事件，异步模式下放入写入队列，name 用于按事件名称合并
+func (s *Writer) send(name string, data []byte) error {
+	if s.queue != nil {
+		return s.queue.push(s.ctx, name, bytes.Clone(data))
+	}
+	return s.writeAndFlush(data)
+}
+
 // writeAndFlush 写入数据并刷新
 func (s *Writer) writeAndFlush(data []byte) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if _, err := s.w.Write(data); err != nil {
-		return err
-	}
-	s.flusher.Flush()
-	return nil
+	_, err := s.Write(data)
+	return err
 }
 
 // Write 写入原始字节数据并立即刷新（实现 io.Writer 接口）
+// 异步模式下放入写入队列，写入错误在后续调用中返回
 func (s *Writer) Write(data []byte) (int, error) {
+	if s.queue != nil {
+		if err := s.queue.push(s.ctx, "", bytes.Clone(data)); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	defer s.deadline()()
 	n, err := s.w.Write(data)
 	if err != nil {
 		return n, err
 	}
 	s.flusher.Flush()
+	s.written.Add(1)
 	return n, nil
 }
 
@@ -268,7 +376,7 @@ func (s *Writer) WriteEvent(data any) error {
 	s.writeData(buf, jsonData)
 	buf.Write(lineEnd)
 
-	return s.writeAndFlush(buf.Bytes())
+	return s.send("", buf.Bytes())
 }
 
 // WriteEventWithName 写入带事件名称的 SSE 格式数据
@@ -289,7 +397,7 @@ func (s *Writer) WriteEventWithName(eventName string, data any) error {
 	s.writeData(buf, jsonData)
 	buf.Write(lineEnd)
 
-	return s.writeAndFlush(buf.Bytes())
+	return s.send(eventName, buf.Bytes())
 }
 
 // WriteEventWithID 写入带 ID 的 SSE 事件（支持断线重连）
@@ -308,7 +416,7 @@ func (s *Writer) WriteEventWithID(id string, data any) error {
 	s.writeData(buf, jsonData)
 	buf.Write(lineEnd)
 
-	return s.writeAndFlush(buf.Bytes())
+	return s.send("", buf.Bytes())
 }
 
 // Event SSE 完整事件结构
@@ -341,7 +449,7 @@ func (s *Writer) WriteFullEvent(event Event) error {
 	s.writeData(buf, jsonData)
 	buf.Write(lineEnd)
 
-	return s.writeAndFlush(buf.Bytes())
+	return s.send(event.Event, buf.Bytes())
 }
 
 // WriteRawEvent 写入原始字符串数据（不进行 JSON 序列化）
@@ -352,12 +460,12 @@ func (s *Writer) WriteRawEvent(data string) error {
 	s.writeData(buf, []byte(data))
 	buf.Write(lineEnd)
 
-	return s.writeAndFlush(buf.Bytes())
+	return s.send("", buf.Bytes())
 }
 
 // WriteDone 发送结束标记 [DONE]
 func (s *Writer) WriteDone() error {
-	return s.writeAndFlush(doneMessage)
+	return s.send("", doneMessage)
 }
 
 // WriteError 发送错误信息
@@ -396,7 +504,7 @@ func (s *Writer) WriteComment(comment string) error {
 	}
 	buf.Write(lineEnd)
 
-	return s.writeAndFlush(buf.Bytes())
+	return s.send("", buf.Bytes())
 }
 
 // SetRetry 设置客户端重连间隔（毫秒）
@@ -408,22 +516,36 @@ func (s *Writer) SetRetry(ms int) error {
 	buf.WriteString(strconv.Itoa(ms))
 	buf.Write(eventEnd)
 
-	return s.writeAndFlush(buf.Bytes())
+	return s.send("", buf.Bytes())
 }
 
+// heartbeatStopWait 停止心跳时等待进行中的心跳写入完成的最长时间
+const heartbeatStopWait = 100 * time.Millisecond
+
 // StartHeartbeat 启动心跳（返回停止函数）
+// 停止函数返回后不会再发起新的心跳；进行中的心跳写入最多等待 100ms，
+// 客户端停止读取导致写入阻塞时不再等待，该心跳可能在之后写入
 func (s *Writer) StartHeartbeat(interval time.Duration) func() {
 	if interval <= 0 {
 		return func() {}
 	}
 	ticker := time.NewTicker(interval)
 	done := make(chan struct{})
+	exited := make(chan struct{})
+	var once sync.Once
 
 	go func() {
+		defer close(exited)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
+				// 停止与触发同时就绪时优先停止
+				select {
+				case <-done:
+					return
+				default:
+				}
 				if err := s.WriteComment("heartbeat"); err != nil {
 					// 如果写入失败（如连接已断开），停止心跳
 					return
@@ -437,10 +559,12 @@ func (s *Writer) StartHeartbeat(interval time.Duration) func() {
 	}()
 
 	return func() {
+		once.Do(func() { close(done) })
+		timer := time.NewTimer(heartbeatStopWait)
+		defer timer.Stop()
 		select {
-		case <-done:
-		default:
-			close(done)
+		case <-exited:
+		case <-timer.C:
 		}
 	}
 }
@@ -477,6 +477,40 @@ func TestStartHeartbeat(t *testing.T) {
 		}
 	})
 
+	t.Run("stalled_client", func(t *testing.T) {
+		slow := &slowResponseWriter{mockResponseWriter: newMockResponseWriter(), gate: make(chan struct{})}
+		writer := &Writer{w: slow, flusher: slow, ctx: context.Background()}
+		stop := writer.StartHeartbeat(5 * time.Millisecond)
+		// 等待心跳阻塞在写入上
+		deadline := time.Now().Add(time.Second)
+		for writer.mu.TryLock() {
+			writer.mu.Unlock()
+			if time.Now().After(deadline) {
+				t.Fatal("heartbeat was not written")
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		stopped := make(chan struct{})
+		go func() {
+			stop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("stop should not block on a stalled client")
+		}
+		close(slow.gate)
+		for !strings.Contains(slow.Body(), ": heartbeat\n") && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if count := strings.Count(slow.Body(), ": heartbeat\n"); count != 1 {
+			t.Errorf("expected only the in-flight heartbeat, got %d", count)
+		}
+	})
+
 	t.Run("zero_interval", func(t *testing.T) {
 		writer, _ := newTestWriter()
 
@@ -586,6 +620,90 @@ func TestStreamContext(t *testing.T) {
 			t.Errorf("Value() should propagate from parent context")
 		}
 	})
+
+	t.Run("client_disconnect", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		ctx := newStreamContext(parent, 0)
+
+		cancel()
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("request cancellation should cancel stream context")
+		}
+		if ctx.Err() != context.Canceled {
+			t.Errorf("Err() = %v, want %v", ctx.Err(), context.Canceled)
+		}
+	})
+
+	t.Run("timeout_is_not_disconnect", func(t *testing.T) {
+		parent, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		ctx := newStreamContext(parent, 0)
+		defer ctx.cancel(context.Canceled)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout should not cancel stream context")
+		case <-time.After(20 * time.Millisecond):
+		}
+	})
+
+	t.Run("raw_context_after_timeout", func(t *testing.T) {
+		// 模拟 Filter 保存的原始请求 context 与 Kratos 超时 context
+		raw, disconnect := context.WithCancel(context.Background())
+		withRaw := context.WithValue(raw, rawContextKey{}, context.Context(raw))
+		parent, cancel := context.WithTimeout(withRaw, time.Millisecond)
+		defer cancel()
+		ctx := newStreamContext(parent, 0)
+
+		<-parent.Done()
+		time.Sleep(5 * time.Millisecond)
+		if ctx.Err() != nil {
+			t.Fatalf("timeout should not cancel stream context, got %v", ctx.Err())
+		}
+		disconnect()
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("disconnect after timeout should cancel stream context")
+		}
+	})
+
+	t.Run("max_lifetime", func(t *testing.T) {
+		ctx := newStreamContext(context.Background(), 10*time.Millisecond)
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("max lifetime should set deadline")
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("stream context should end after max lifetime")
+		}
+		if ctx.Err() != context.DeadlineExceeded {
+			t.Errorf("Err() = %v, want %v", ctx.Err(), context.DeadlineExceeded)
+		}
+	})
+}
+
+// unwrapResponseWriter 不实现 Flusher，仅通过 Unwrap 暴露底层 ResponseWriter
+type unwrapResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (u unwrapResponseWriter) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+func TestFindFlusher(t *testing.T) {
+	mock := newMockResponseWriter()
+	f, ok := findFlusher(unwrapResponseWriter{mock})
+	if !ok || f != http.Flusher(mock) {
+		t.Fatal("should find flusher through Unwrap")
+	}
+	if _, ok := findFlusher(unwrapResponseWriter{struct{ http.ResponseWriter }{mock}}); ok {
+		t.Fatal("should not find flusher")
+	}
 }
 
 // =============================================================================
@@ -0,0 +1,162 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+)
+
+type streamOptions struct {
+	done       string
+	errorEvent string
+	heartbeat  time.Duration
+	writer     []WriterOption
+}
+
+// StreamOption 迭代器流式输出选项
+type StreamOption func(*streamOptions)
+
+// WithStreamDone 设置结束标记，默认 [DONE]，为空时不发送
+func WithStreamDone(sentinel string) StreamOption {
+	return func(o *streamOptions) {
+		o.done = sentinel
+	}
+}
+
+// WithStreamErrorEvent 设置出错时 error 事件的名称，默认 error
+func WithStreamErrorEvent(name string) StreamOption {
+	return func(o *streamOptions) {
+		o.errorEvent = name
+	}
+}
+
+// WithStreamHeartbeat 设置心跳间隔，默认不发送
+func WithStreamHeartbeat(d time.Duration) StreamOption {
+	return func(o *streamOptions) {
+		o.heartbeat = d
+	}
+}
+
+// WithStreamWriterOptions 设置 StreamSeq 等函数创建 Writer 时的选项
+func WithStreamWriterOptions(opts ...WriterOption) StreamOption {
+	return func(o *streamOptions) {
+		o.writer = append(o.writer, opts...)
+	}
+}
+
+func newStreamOptions(opts ...StreamOption) streamOptions {
+	o := streamOptions{
+		done:       "[DONE]",
+		errorEvent: "error",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// MapSeq 将迭代器中的值映射为事件，用于指定事件名称与 ID，错误原样传递
+func MapSeq[T any](seq iter.Seq2[T, error], fn func(T) Event) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		for v, err := range seq {
+			if err != nil {
+				yield(Event{}, err)
+				return
+			}
+			if !yield(fn(v), nil) {
+				return
+			}
+		}
+	}
+}
+
+// MapValues 将不会出错的迭代器中的值映射为事件
+func MapValues[T any](seq iter.Seq[T], fn func(T) Event) iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		for v := range seq {
+			if !yield(fn(v)) {
+				return
+			}
+		}
+	}
+}
+
+// toEvent Event 类型的值原样写入，其他值作为 Data 写入
+func toEvent[T any](v T) Event {
+	if e, ok := any(v).(Event); ok {
+		return e
+	}
+	return Event{Data: v}
+}
+
+// WriteSeq 将迭代器中的每个值写入为事件，直到迭代结束、出错或客户端断开
+// Event 类型的值原样写入，其他值作为 Data 写入，需要指定事件名称与 ID 时使用 MapSeq；
+// 迭代结束时发送结束标记；迭代出错时发送 error 事件（数据为 ErrorData）并返回该错误；客户端断开时停止迭代并返回 context 错误
+func WriteSeq[T any](w *Writer, seq iter.Seq2[T, error], opts ...StreamOption) error {
+	o := newStreamOptions(opts...)
+	return writeSeq(w, seq, &o)
+}
+
+// StreamSeq 创建 Writer 并写入迭代器中的值，参见 WriteSeq
+// 迭代器应在客户端断开时尽快结束，需要感知断开时使用 StreamSeqFunc
+func StreamSeq[T any](ctx context.Context, seq iter.Seq2[T, error], opts ...StreamOption) error {
+	return StreamSeqFunc(ctx, func(context.Context) iter.Seq2[T, error] { return seq }, opts...)
+}
+
+// StreamSeqFunc 创建 Writer，以无超时、随客户端断开取消的 context 创建迭代器并写入
+//
+//	return nil, sse.StreamSeqFunc(ctx, func(ctx context.Context) iter.Seq2[sse.Event, error] {
+//		return sse.MapSeq(s.uc.Generate(ctx, req), func(m *Message) sse.Event {
+//			return sse.Event{ID: m.ID, Event: "message", Data: m}
+//		})
+//	})
+func StreamSeqFunc[T any](ctx context.Context, fn func(ctx context.Context) iter.Seq2[T, error], opts ...StreamOption) error {
+	o := newStreamOptions(opts...)
+	w, streamCtx, err := NewWriter(ctx, o.writer...)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return writeSeq(w, fn(streamCtx), &o)
+}
+
+// StreamValues 创建 Writer 并写入不会出错的迭代器中的值，参见 WriteSeq
+func StreamValues[T any](ctx context.Context, seq iter.Seq[T], opts ...StreamOption) error {
+	return StreamSeq(ctx, func(yield func(T, error) bool) {
+		for v := range seq {
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}, opts...)
+}
+
+func writeSeq[T any](w *Writer, seq iter.Seq2[T, error], o *streamOptions) error {
+	if o.heartbeat > 0 {
+		stop := w.StartHeartbeat(o.heartbeat)
+		defer stop()
+	}
+	for v, err := range seq {
+		// 客户端断开时停止迭代
+		if ctxErr := w.Context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if werr := w.WriteFullEvent(Event{Event: o.errorEvent, Data: NewErrorData(err)}); werr != nil {
+				return errors.Join(err, werr)
+			}
+			return err
+		}
+		if err := w.WriteFullEvent(toEvent(v)); err != nil {
+			return err
+		}
+	}
+	if err := w.Context().Err(); err != nil {
+		return err
+	}
+	if o.done == "" {
+		return nil
+	}
+	return w.WriteRawEvent(o.done)
+}
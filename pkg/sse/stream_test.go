@@ -0,0 +1,125 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+func seqOf[T any](err error, values ...T) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for _, v := range values {
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+func TestWriteSeq(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		writer, mock := newTestWriter()
+		if err := WriteSeq(writer, seqOf[int](nil, 1, 2)); err != nil {
+			t.Fatal(err)
+		}
+		if want := "data: 1\n\ndata: 2\n\ndata: [DONE]\n\n"; mock.Body() != want {
+			t.Fatalf("body = %q, want %q", mock.Body(), want)
+		}
+	})
+
+	t.Run("mapper and custom done", func(t *testing.T) {
+		writer, mock := newTestWriter()
+		err := WriteSeq(writer, MapSeq(seqOf[int](nil, 1), func(n int) Event {
+			return Event{ID: strconv.Itoa(n), Event: "count", Data: n * 10}
+		}), WithStreamDone(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "id: 1\nevent: count\ndata: 10\n\n"; mock.Body() != want {
+			t.Fatalf("body = %q, want %q", mock.Body(), want)
+		}
+	})
+
+	t.Run("error event", func(t *testing.T) {
+		writer, mock := newTestWriter()
+		failure := kerrors.NotFound("USER_NOT_FOUND", "user not found")
+		err := WriteSeq(writer, seqOf(failure, Event{Event: "message", Data: "hi"}))
+		if !errors.Is(err, failure) {
+			t.Fatalf("unexpected err %v", err)
+		}
+		events, _ := parseAll(t, mock.Body())
+		if len(events) != 2 || events[0].Event != "message" || events[1].Event != "error" {
+			t.Fatalf("unexpected events %+v", events)
+		}
+		data, _ := Decode[ErrorData](events[1])
+		if data.Code != 404 || data.Reason != "USER_NOT_FOUND" || data.Error != "user not found" {
+			t.Fatalf("unexpected error data %+v", data)
+		}
+	})
+
+	// 映射的类型在编译期检查，迭代错误原样传递
+	t.Run("mapper error", func(t *testing.T) {
+		writer, mock := newTestWriter()
+		failure := errors.New("boom")
+		err := WriteSeq(writer, MapSeq(seqOf(failure, "a"), func(s string) Event {
+			return Event{Event: "letter", Data: s}
+		}))
+		if !errors.Is(err, failure) {
+			t.Fatalf("unexpected err %v", err)
+		}
+		events, _ := parseAll(t, mock.Body())
+		if len(events) != 2 || events[0].Event != "letter" || events[1].Event != "error" {
+			t.Fatalf("unexpected events %+v", events)
+		}
+	})
+}
+
+func TestMapValues(t *testing.T) {
+	var ids []string
+	for e := range MapValues(slices.Values([]int{1, 2, 3}), func(n int) Event { return Event{ID: strconv.Itoa(n)} }) {
+		ids = append(ids, e.ID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(ids, []string{"1", "2"}) {
+		t.Fatalf("ids = %v", ids)
+	}
+}
+
+func TestStreamSeqFunc(t *testing.T) {
+	srv := khttp.NewServer()
+	srv.HandleFunc("/stream", func(w khttp.ResponseWriter, r *khttp.Request) {
+		_ = StreamSeqFunc(r.Context(), func(ctx context.Context) iter.Seq2[string, error] {
+			if _, ok := ctx.Deadline(); ok {
+				t.Error("stream context should not have a deadline")
+			}
+			return seqOf[string](nil, "a", "b")
+		}, WithStreamDone("END"))
+	})
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if want := "data: \"a\"\n\ndata: \"b\"\n\ndata: END\n\n"; string(body) != want {
+		t.Fatalf("body = %q, want %q", body, want)
+	}
+}